@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	ampImgRe    = regexp.MustCompile(`<img src="([^"]*)" alt="([^"]*)"([^>]*)>`)
+	ampMediaRe  = regexp.MustCompile(`<(audio|video) controls src="([^"]*)"[^>]*>(.*?)</(?:audio|video)>`)
+	ampButtonRe = regexp.MustCompile(`<button[^>]*>.*?</button>\n?`)
+	// inline event handlers and style attributes are not allowed in AMP
+	ampAttrRe = regexp.MustCompile(` (?:on[a-z]+|style)="[^"]*"`)
+)
+
+// ampContent rewrites rendered post content to the AMP subset: images become
+// <amp-img> with their dimensions, audio and video become plain links, and
+// buttons and disallowed attributes are dropped. Images whose size can't be
+// read are left out.
+func ampContent(content string, post Post) string {
+	content = ampButtonRe.ReplaceAllString(content, "")
+	content = ampAttrRe.ReplaceAllString(content, "")
+	content = ampMediaRe.ReplaceAllString(content, `<a href="$2">$3</a>`)
+	return ampImgRe.ReplaceAllStringFunc(content, func(m string) string {
+		sub := ampImgRe.FindStringSubmatch(m)
+		w, h, err := imageSize(html.UnescapeString(sub[1]), path.Dir(post.URL))
+		if err != nil {
+			buildLog.Warnf(post.Slug, "AMP: dropping image %s: %v", sub[1], err)
+			return ""
+		}
+		return fmt.Sprintf(`<amp-img src="%s" alt="%s"%s width="%d" height="%d" layout="responsive"></amp-img>`, sub[1], sub[2], sub[3], w, h)
+	})
+}
+
+// localImage returns the file under public/ an image src refers to, src
+// being relative to the page directory dir or to the site root.
+func localImage(src, dir string) (file string, ok bool) {
+	switch {
+	case isRelativeURL(src):
+		file = path.Join(filepath.ToSlash(outputDir), dir, src)
+	case strings.HasPrefix(src, "/") && !strings.HasPrefix(src, "//"):
+		file = path.Join(filepath.ToSlash(outputDir), src)
+	default:
+		return "", false
+	}
+	return filepath.FromSlash(file), true
+}
+
+// imageSize reads the dimensions of an image under public/, see localImage.
+func imageSize(src, dir string) (width, height int, err error) {
+	file, ok := localImage(src, dir)
+	if !ok {
+		return 0, 0, fmt.Errorf("not a local image")
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0, err
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
+// generateAMP writes an <slug>.amp.html next to every post's page using
+// amp.html, with style.css inlined as the page's only stylesheet.
+func generateAMP(posts []Post) error {
+	tmpl, err := loadTemplate("amp.html")
+	if err != nil {
+		return fmt.Errorf("AMP: %w", err)
+	}
+	css := siteCSS()
+	var errs []error
+	for _, post := range posts {
+		post.Content = template.HTML(ampContent(string(post.Content), post))
+		err := renderPage(tmpl, filepath.Join(outputDir, filepath.FromSlash(ampURL(post))), struct {
+			Post
+			Canonical string
+			CSS       template.CSS
+			Root      string
+		}{
+			Post:      post,
+			Canonical: config.BaseURL + "/" + post.URL,
+			CSS:       template.CSS(css),
+			Root:      pageRoot(post.URL),
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", post.Source, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestAMPImages(t *testing.T) {
+	var img bytes.Buffer
+	png.Encode(&img, image.NewGray(image.Rect(0, 0, 30, 20)))
+	testSite(t, map[string]string{
+		"index.html":                  "",
+		"article.html":                "{{.Content}}",
+		"amp.html":                    "{{.Content}}",
+		"public/images/photo.png":     img.String(),
+		"articles/2024-01-01-post.md": "# Post\n\n![a photo](../images/photo.png \"Title\")\n\n![gone](../images/missing.png)\n\n```\ncode\n```\n",
+	})
+	config.AMP = true
+	if err := buildSite(); err != nil {
+		t.Fatal(err)
+	}
+	page := readOutput(t, "articles/2024-01-01-post.amp.html")
+	want := `<amp-img src="../images/photo.png" alt="a photo" title="Title" width="30" height="20" layout="responsive"></amp-img>`
+	if !strings.Contains(page, want) {
+		t.Errorf("AMP page lacks %s:\n%s", want, page)
+	}
+	for _, unwanted := range []string{"<img", "missing.png", "<button", "onclick="} {
+		if strings.Contains(page, unwanted) {
+			t.Errorf("AMP page contains %s:\n%s", unwanted, page)
+		}
+	}
+	// the regular page keeps its <img>
+	if got := readOutput(t, "articles/2024-01-01-post.html"); !strings.Contains(got, `<img src="../images/photo.png"`) {
+		t.Errorf("article lost its image:\n%s", got)
+	}
+}
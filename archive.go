@@ -0,0 +1,103 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// writeArchive packs every file below dir into dest, a .tar.gz (or .tgz) or
+// .zip file chosen by extension, with paths relative to dir.
+func writeArchive(dir, dest string) error {
+	var add func(name string, info fs.FileInfo, r io.Reader) error
+	var finish func() error
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	switch lower := strings.ToLower(dest); {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		gz := gzip.NewWriter(out)
+		tw := tar.NewWriter(gz)
+		add = func(name string, info fs.FileInfo, r io.Reader) error {
+			hdr, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			hdr.Name = name
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			_, err = io.Copy(tw, r)
+			return err
+		}
+		finish = func() error {
+			if err := tw.Close(); err != nil {
+				return err
+			}
+			return gz.Close()
+		}
+	case strings.HasSuffix(lower, ".zip"):
+		zw := zip.NewWriter(out)
+		add = func(name string, info fs.FileInfo, r io.Reader) error {
+			hdr, err := zip.FileInfoHeader(info)
+			if err != nil {
+				return err
+			}
+			hdr.Name = name
+			hdr.Method = zip.Deflate
+			w, err := zw.CreateHeader(hdr)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(w, r)
+			return err
+		}
+		finish = zw.Close
+	default:
+		os.Remove(dest)
+		return fmt.Errorf("unsupported archive %s, use .tar.gz, .tgz or .zip", dest)
+	}
+
+	absDest, _ := filepath.Abs(dest)
+	count := 0
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		// an archive written into dir must not contain itself
+		if abs, _ := filepath.Abs(path); abs == absDest {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		count++
+		return add(filepath.ToSlash(rel), info, f)
+	})
+	if err != nil {
+		return err
+	}
+	if err := finish(); err != nil {
+		return err
+	}
+	buildLog.Printf("", "archived %d files to %s", count, dest)
+	return out.Close()
+}
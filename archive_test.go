@@ -0,0 +1,106 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteArchive(t *testing.T) {
+	files := map[string]string{
+		"public/index.html":             "index",
+		"public/articles/post.html":     "post",
+		"public/images/deep/photo.png":  "png",
+		"public/.manifest.json":         "[]",
+		"elsewhere/not-in-archive.html": "x",
+	}
+	want := map[string]string{
+		"index.html":            "index",
+		"articles/post.html":    "post",
+		"images/deep/photo.png": "png",
+		".manifest.json":        "[]",
+	}
+	tests := []struct {
+		name string
+		dest string
+		read func(t *testing.T, path string) map[string]string
+	}{
+		{"tar.gz", "site.tar.gz", readTarGz},
+		{"tgz", "site.tgz", readTarGz},
+		{"zip", "site.zip", readZip},
+		// written into the directory it packs, without containing itself
+		{"inside", "public/site.zip", readZip},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testSite(t, files)
+			if err := writeArchive(outputDir, tt.dest); err != nil {
+				t.Fatal(err)
+			}
+			if got := tt.read(t, tt.dest); !maps.Equal(got, want) {
+				t.Errorf("archive holds %v, want %v", got, want)
+			}
+		})
+	}
+
+	t.Run("unsupported", func(t *testing.T) {
+		testSite(t, files)
+		if err := writeArchive(outputDir, "site.rar"); err == nil {
+			t.Error("no error for an unsupported format")
+		}
+		if _, err := os.Stat("site.rar"); !os.IsNotExist(err) {
+			t.Error("left site.rar behind")
+		}
+	})
+}
+
+func readTarGz(t *testing.T, path string) map[string]string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(tr)
+		files[hdr.Name] = string(data)
+	}
+}
+
+func readZip(t *testing.T, path string) map[string]string {
+	t.Helper()
+	zr, err := zip.OpenReader(filepath.FromSlash(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	files := map[string]string{}
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(r)
+		r.Close()
+		files[f.Name] = string(data)
+	}
+	return files
+}
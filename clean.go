@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// cleanOutput removes what builds generated in dir, and then any directories
+// left empty. That is every file except those in dir/images, where the image
+// command puts the processed images, which are only removed if the last build
+// generated them, as listed in its .manifest.json. Pages of posts deleted
+// since an earlier build are removed as well.
+// It refuses to touch dir if it is a symlink to somewhere outside the project,
+// if it is the project directory or one of its parents, or if it holds the
+// posts, static files or config.json, e.g. after "-out .".
+func cleanOutput(dir string) error {
+	info, err := os.Lstat(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	target, err := resolvePath(dir)
+	if err != nil {
+		return err
+	}
+	wd, err := resolvePath(".")
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 && !within(wd, target) {
+		return fmt.Errorf("refusing to clean %s: symlink to %s is outside the project", dir, target)
+	}
+	for _, keep := range []string{".", inputDir, staticDir, configFile} {
+		path, err := resolvePath(keep)
+		if err != nil {
+			return err
+		}
+		if within(target, path) {
+			return fmt.Errorf("refusing to clean %s: it contains %s", dir, path)
+		}
+	}
+
+	listed := map[string]bool{}
+	data, err := os.ReadFile(filepath.Join(dir, ".manifest.json"))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		var m manifestFile
+		if err := json.Unmarshal(data, &m); err != nil {
+			return fmt.Errorf("reading %s/.manifest.json: %w", dir, err)
+		}
+		for _, e := range m.Files {
+			listed[e.Path] = true
+		}
+	}
+	var dirs []string
+	// the trailing separator makes WalkDir follow dir if it is a symlink
+	err = filepath.WalkDir(dir+string(filepath.Separator), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if d.IsDir() {
+			dirs = append(dirs, path)
+			return nil
+		}
+		if strings.HasPrefix(rel, "images"+string(filepath.Separator)) && !listed[filepath.ToSlash(rel)] {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			buildLog.Warnf("clean", "%v", err)
+			return nil
+		}
+		buildLog.Printf("", "removed: %s", path)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// WalkDir lists parents first, so going backwards they are empty by the
+	// time they are tried
+	for _, d := range slices.Backward(dirs) {
+		if os.Remove(d) == nil {
+			buildLog.Printf("", "removed: %s%c", d, filepath.Separator)
+		}
+	}
+	return nil
+}
+
+// resolvePath returns path as an absolute path with symlinks evaluated, as
+// far as it exists.
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := filepath.EvalSymlinks(abs)
+	if os.IsNotExist(err) {
+		return abs, nil
+	}
+	return resolved, err
+}
+
+// within reports whether path is dir or below it.
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && filepath.IsLocal(rel)
+}
+
+func runCleanCommand(args []string) {
+	if err := cleanOutput(outputDir); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCleanOutput(t *testing.T) {
+	testSite(t, map[string]string{
+		"index.html":                  "{{range .Posts}}{{.Title}}{{end}}",
+		"article.html":                "{{.Content}}",
+		"static/images/logo.png":      "logo",
+		"articles/2024-01-01-old.md":  "# Old\n\nText\n",
+		"articles/2024-02-01-post.md": "# Post\n\nText\n",
+		"public/images/photo.png":     "processed",
+	})
+	if err := buildSite(); err != nil {
+		t.Fatal(err)
+	}
+	// the page of a post deleted since is not in the manifest anymore
+	os.Remove("articles/2024-01-01-old.md")
+	if err := buildSite(); err != nil {
+		t.Fatal(err)
+	}
+	readOutput(t, "articles/2024-01-01-old.html")
+	if err := cleanOutput(outputDir); err != nil {
+		t.Fatal(err)
+	}
+	var left []string
+	filepath.WalkDir(outputDir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			left = append(left, filepath.ToSlash(path))
+		}
+		return nil
+	})
+	if strings.Join(left, " ") != "public/images/photo.png" {
+		t.Errorf("clean left %v, want only the processed image", left)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "articles")); !os.IsNotExist(err) {
+		t.Error("clean left the empty articles directory")
+	}
+}
+
+func TestCleanOutputRefusesOutsideSymlink(t *testing.T) {
+	testSite(t, nil)
+	outside := t.TempDir()
+	os.WriteFile(filepath.Join(outside, "index.html"), []byte("keep"), 0644)
+	if err := os.Symlink(outside, outputDir); err != nil {
+		t.Skip(err)
+	}
+	if err := cleanOutput(outputDir); err == nil {
+		t.Error("cleaned a symlink to outside the project")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "index.html")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCleanOutputRefusesProject(t *testing.T) {
+	tests := []struct {
+		name   string
+		out    string
+		in     string
+		config bool
+	}{
+		{"-out .", ".", "articles", false},
+		{"parent", "..", "articles", false},
+		{"dotted", "articles/..", "articles", false},
+		{"posts inside", "public", "public/posts", false},
+		{"config inside", "site", "articles", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := map[string]string{
+				"index.html":                   "template",
+				"articles/2024-01-01-post.md":  "# Post\n",
+				"public/posts/2024-01-01-x.md": "# X\n",
+			}
+			if tt.config {
+				files["site/index.html"] = "page"
+				files["site/"+configFile] = "{}"
+			}
+			testSite(t, files)
+			if tt.config {
+				// the project's config file is a symlink into the output
+				if err := os.Symlink(filepath.Join("site", configFile), configFile); err != nil {
+					t.Skip(err)
+				}
+			}
+			outputDir, inputDir = tt.out, tt.in
+			if err := cleanOutput(outputDir); err == nil {
+				t.Errorf("cleaned %s", tt.out)
+			}
+			for name := range files {
+				if _, err := os.Stat(name); err != nil {
+					t.Errorf("%s was removed", name)
+				}
+			}
+		})
+	}
+}
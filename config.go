@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// configFile, if present in the working directory, overrides the compiled
+// defaults from data.go.
+const configFile = "config.json"
+
+// loadConfigFile sets every field named in the JSON object in path on cfg,
+// matching field names case-insensitively. A field in the file replaces the
+// compiled value completely, maps and slices are not merged. A missing file
+// leaves cfg unchanged.
+func loadConfigFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var settings map[string]json.RawMessage
+	if err := json.Unmarshal(data, &settings); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line := 1 + bytes.Count(data[:syntaxErr.Offset], []byte("\n"))
+			return fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	v := reflect.ValueOf(cfg).Elem()
+	for key, raw := range settings {
+		field, ok := v.Type().FieldByNameFunc(func(name string) bool { return strings.EqualFold(name, key) })
+		if !ok {
+			return fmt.Errorf("%s: unknown setting %q", path, key)
+		}
+		value := reflect.New(field.Type)
+		if err := json.Unmarshal(raw, value.Interface()); err != nil {
+			return fmt.Errorf("%s: %s: %v", path, field.Name, err)
+		}
+		v.FieldByIndex(field.Index).Set(value.Elem())
+	}
+	return nil
+}
+
+var envRe = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces ${VAR} references with the value of the environment
+// variable. Unset variables expand to an empty string with a warning.
+func expandEnv(s string) string {
+	return envRe.ReplaceAllStringFunc(s, func(ref string) string {
+		name := envRe.FindStringSubmatch(ref)[1]
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			buildLog.Warnf("config", "unset environment variable %s", name)
+		}
+		return value
+	})
+}
+
+// expandConfigEnv applies expandEnv to every string in the config, including
+// map keys and values and strings nested in slices and structs.
+func expandConfigEnv(cfg *Config) {
+	expandValue(reflect.ValueOf(cfg).Elem())
+}
+
+func expandValue(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(expandEnv(v.String()))
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			expandValue(v.Field(i))
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			expandValue(v.Index(i))
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String || v.Type().Elem().Kind() != reflect.String {
+			return
+		}
+		expanded := reflect.MakeMap(v.Type())
+		for _, k := range v.MapKeys() {
+			expanded.SetMapIndex(reflect.ValueOf(expandEnv(k.String())), reflect.ValueOf(expandEnv(v.MapIndex(k).String())))
+		}
+		if v.CanSet() {
+			v.Set(expanded)
+		}
+	}
+}
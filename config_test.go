@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestConfigEnvironment(t *testing.T) {
+	testSite(t, map[string]string{configFile: `{
+		"BaseURL": "${BASE_URL}",
+		"Title": "${UNSET_TEST_VARIABLE}blog",
+		"Links": {"${LINK_NAME}": "${BASE_URL}/about"}
+	}`})
+	t.Setenv("BASE_URL", "https://staging.example.com")
+	t.Setenv("LINK_NAME", "About")
+	if err := loadConfigFile(configFile, &config); err != nil {
+		t.Fatal(err)
+	}
+	expandConfigEnv(&config)
+	if config.BaseURL != "https://staging.example.com" {
+		t.Errorf("BaseURL = %q", config.BaseURL)
+	}
+	if config.Title != "blog" {
+		t.Errorf("Title = %q, an unset variable should expand to nothing", config.Title)
+	}
+	if got := config.Links["About"]; got != "https://staging.example.com/about" {
+		t.Errorf("Links = %v", config.Links)
+	}
+}
@@ -29,6 +29,8 @@ var config = Config{
 		"[oliverselinger/db-evolve](https://github.com/oliverselinger/db-evolve)":                 "database migration tool for Java 11+ projects (lightweight alternative to liquibase or flyway)",
 		"[nobloat/svelte-router](https://github.com/nobloat/svelte-router)":                       "router for svelte, in case one does not want to use SvelteKit",
 	},
+	FeedMaxItems: 20,
+	PostsPerPage: 10,
 	Tools: []Tool{
 		{Name: "bundlephobia", Description: "A tool to analyze the size of your JavaScript packages", URL: "https://bundlephobia.com/"},
 	},
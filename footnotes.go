@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	footnoteDefRe = regexp.MustCompile(`^\[\^([^\]\s]+)\]:\s*(.*)$`)
+	// inline ^[note] or reference [^id], matched together to keep their order
+	footnoteRefRe    = regexp.MustCompile(`\^\[([^\]]+)\]|\[\^([^\]\s]+)\]`)
+	footnoteMarkerRe = regexp.MustCompile("\x01([0-9]+)\x01")
+	// a rendered reference, see resolve
+	footnoteRefHTMLRe = regexp.MustCompile(`<sup class="footnote-ref"[^>]*><a href="#fn-[0-9]+">[0-9]+</a></sup>`)
+)
+
+// footnotes numbers reference-style [^id] and inline ^[text] footnotes in
+// order of first appearance and renders them at the end of a document.
+type footnotes struct {
+	defs  map[string]string // reference id -> markdown text
+	ids   map[string]int    // reference id -> number
+	notes []string          // markdown text by number-1
+	refs  map[int]bool      // numbers whose first reference got the back-link id
+}
+
+// collectFootnotes removes "[^id]: text" definitions outside code fences
+// from lines.
+func collectFootnotes(lines []string) ([]string, *footnotes) {
+	fn := &footnotes{defs: map[string]string{}, ids: map[string]int{}, refs: map[int]bool{}}
+	var kept []string
+	fence := ""
+	for _, raw := range lines {
+		line := strings.TrimSpace(raw)
+		fenceLine(&fence, line)
+		if m := footnoteDefRe.FindStringSubmatch(line); m != nil && fence == "" {
+			fn.defs[m[1]] = m[2]
+			continue
+		}
+		kept = append(kept, raw)
+	}
+	return kept, fn
+}
+
+// link replaces footnote references in a markdown line with numbered markers
+// that survive formatInline. Code spans and undefined references are left alone.
+func (fn *footnotes) link(line string) string {
+	var out strings.Builder
+	last := 0
+	for _, span := range codeRe.FindAllStringIndex(line, -1) {
+		out.WriteString(fn.linkText(line[last:span[0]]))
+		out.WriteString(line[span[0]:span[1]])
+		last = span[1]
+	}
+	out.WriteString(fn.linkText(line[last:]))
+	return out.String()
+}
+
+func (fn *footnotes) linkText(text string) string {
+	return footnoteRefRe.ReplaceAllStringFunc(text, func(m string) string {
+		sub := footnoteRefRe.FindStringSubmatch(m)
+		if sub[1] != "" {
+			fn.notes = append(fn.notes, sub[1])
+			return fmt.Sprintf("\x01%d\x01", len(fn.notes))
+		}
+		if n, ok := fn.ids[sub[2]]; ok {
+			return fmt.Sprintf("\x01%d\x01", n)
+		}
+		def, ok := fn.defs[sub[2]]
+		if !ok {
+			return m
+		}
+		fn.notes = append(fn.notes, def)
+		fn.ids[sub[2]] = len(fn.notes)
+		return fmt.Sprintf("\x01%d\x01", len(fn.notes))
+	})
+}
+
+// resolve turns the markers in rendered HTML into superscript links. Only the
+// first reference to a note carries the id the note links back to.
+func (fn *footnotes) resolve(rendered string) string {
+	return footnoteMarkerRe.ReplaceAllStringFunc(rendered, func(m string) string {
+		n, _ := strconv.Atoi(footnoteMarkerRe.FindStringSubmatch(m)[1])
+		if fn.refs[n] {
+			return fmt.Sprintf(`<sup class="footnote-ref"><a href="#fn-%d">%d</a></sup>`, n, n)
+		}
+		fn.refs[n] = true
+		return fmt.Sprintf(`<sup class="footnote-ref" id="fnref-%d"><a href="#fn-%d">%d</a></sup>`, n, n, n)
+	})
+}
+
+// section renders the collected notes, or nothing if there are none.
+func (fn *footnotes) section() string {
+	if len(fn.notes) == 0 {
+		return ""
+	}
+	var out strings.Builder
+	out.WriteString("<section class=\"footnotes\">\n<ol>\n")
+	for i, note := range fn.notes {
+		out.WriteString(fmt.Sprintf("<li id=\"fn-%d\">%s <a href=\"#fnref-%d\" aria-label=\"Back to text\">↩</a></li>\n", i+1, formatInline(note), i+1))
+	}
+	out.WriteString("</ol>\n</section>\n")
+	return out.String()
+}
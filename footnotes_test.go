@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFootnotes(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		content []string // expected in order
+	}{
+		{
+			"inline and reference in one paragraph",
+			"Text with an inline^[the *inline* note] and a reference[^ref] note.\n\n[^ref]: the reference note\n",
+			[]string{
+				`inline<sup class="footnote-ref" id="fnref-1"><a href="#fn-1">1</a></sup>`,
+				`reference<sup class="footnote-ref" id="fnref-2"><a href="#fn-2">2</a></sup>`,
+				`<li id="fn-1">the <em>inline</em> note <a href="#fnref-1"`,
+				`<li id="fn-2">the reference note <a href="#fnref-2"`,
+			},
+		},
+		{
+			"reference first and repeated",
+			"A[^a] then^[inline] then A again[^a].\n\n[^a]: note a\n",
+			[]string{
+				`A<sup class="footnote-ref" id="fnref-1"><a href="#fn-1">1</a></sup>`,
+				`then<sup class="footnote-ref" id="fnref-2"><a href="#fn-2">2</a></sup>`,
+				`again<sup class="footnote-ref"><a href="#fn-1">1</a></sup>`,
+				`<li id="fn-1">note a`,
+				`<li id="fn-2">inline`,
+			},
+		},
+		{
+			"undefined reference and code",
+			"Missing[^x] and `code[^a]`.\n\n[^a]: unused\n",
+			[]string{"Missing[^x] and <code>code[^a]</code>."},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, _, _, _ := parseMarkdown(tt.input, "test.md")
+			rest := content
+			for _, want := range tt.content {
+				i := strings.Index(rest, want)
+				if i < 0 {
+					t.Fatalf("content lacks %q after the previous part:\n%s", want, content)
+				}
+				rest = rest[i+len(want):]
+			}
+		})
+	}
+}
+
+func TestFootnotesLeftOutOfExcerpt(t *testing.T) {
+	testSite(t, map[string]string{
+		"articles/2024-01-01-post.md": "# Post\n\nText with an inline^[note] and a reference[^ref] note.\n\n[^ref]: the reference\n",
+	})
+	posts, _, err := loadPosts(inputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(posts[0].ExcerptHTML), "Text with an inline and a reference note."; got != want {
+		t.Errorf("ExcerptHTML = %q, want %q", got, want)
+	}
+	if got, want := posts[0].Excerpt, "Text with an inline and a reference note."; got != want {
+		t.Errorf("Excerpt = %q, want %q", got, want)
+	}
+}
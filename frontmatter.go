@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+)
+
+// parseFrontMatter splits an optional leading block delimited by "---" lines
+// off the markdown source. The block holds simple "key: value" pairs; anything
+// fancier than that is not needed for per-post metadata. The one exception are
+// lists, written either as "key: [a, b]" or as "- a" lines below "key:",
+// which end up as "a, b", see splitList.
+func parseFrontMatter(input string) (meta map[string]string, body string) {
+	meta = map[string]string{}
+	if !strings.HasPrefix(input, "---\n") {
+		return meta, input
+	}
+	end := strings.Index(input[4:], "\n---")
+	if end < 0 {
+		return meta, input
+	}
+	block := input[4 : 4+end]
+	body = strings.TrimPrefix(input[4+end+4:], "\n")
+	lastKey := ""
+	for _, line := range strings.Split(block, "\n") {
+		if item, ok := strings.CutPrefix(strings.TrimSpace(line), "- "); ok && lastKey != "" {
+			item = strings.Trim(strings.TrimSpace(item), `"'`)
+			if meta[lastKey] != "" {
+				item = meta[lastKey] + ", " + item
+			}
+			meta[lastKey] = item
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+			value = strings.Join(splitList(value[1:len(value)-1]), ", ")
+		}
+		value = strings.Trim(value, `"'`)
+		lastKey = strings.ToLower(strings.TrimSpace(key))
+		meta[lastKey] = value
+	}
+	return meta, body
+}
+
+// splitList splits a comma separated front matter value into its trimmed,
+// unquoted, non-empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.Trim(strings.TrimSpace(item), `"'`); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
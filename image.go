@@ -3,16 +3,23 @@
 package main
 
 import (
+	"bytes"
+	"flag"
+	"fmt"
 	"image"
 	"image/color"
+	"image/draw"
 	_ "image/jpeg"
 	"image/png"
 	"log"
 	"math"
 	"os"
-	"path"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 const (
@@ -27,56 +34,328 @@ const (
 )
 
 func runImageCommand(args []string) {
+	fs := flag.NewFlagSet("image", flag.ExitOnError)
+	bgHex := fs.String("bg", "#ffffff", "background color for transparent areas and padding")
+	var opts imageOptions
+	fs.IntVar(&opts.Pad, "pad", 0, "padding in output pixels around the image")
+	fs.IntVar(&opts.Size, "size", maxLongEdge, "maximum length of the longer edge in pixels, smaller images keep their size")
+	srcset := fs.String("srcset", "", "comma separated sizes like 200,400,800 to write name@200w.png, ... instead of a single image, skipping sizes above the source's")
+	outPath := fs.String("out", "", "output file, or directory for a directory input (default "+filepath.Join(outputDir, "images")+")")
+	fs.IntVar(&opts.Levels, "levels", 2, "number of gray levels to dither to, 2 is pure black and white (2-256)")
+	fs.IntVar(&opts.Threshold, "threshold", ditherThreshold, "gray value above which a pixel turns white with 2 levels (0-255)")
+	fs.StringVar(&opts.Dither, "dither", "floyd", "dithering method: floyd, atkinson, ordered or none")
+	fs.BoolVar(&opts.Gray, "gray", false, "write smooth 8-bit grayscale, skipping the dithering (-levels and -dither are ignored)")
+	fs.BoolVar(&opts.Sharpen, "sharpen", true, "apply unsharp masking")
+	fs.Float64Var(&opts.Sigma, "sigma", unsharpSigma, "blur radius of the unsharp mask in pixels (> 0)")
+	fs.Float64Var(&opts.Amount, "amount", unsharpAmount, "strength of the unsharp mask, 0 leaves the image as it is (>= 0)")
+	fs.BoolVar(&opts.Sigmoid, "sigmoid", true, "apply sigmoid contrast")
+	fs.Float64Var(&opts.Contrast, "contrast", sigmoidContrast, "steepness of the sigmoid contrast curve (> 0)")
+	fs.Float64Var(&opts.Midpoint, "midpoint", sigmoidMidpoint, "gray value the sigmoid curve is centered on (0-1)")
+	fs.BoolVar(&opts.Stretch, "stretch", true, "stretch the histogram to the full range")
+	fs.Float64Var(&opts.Black, "black", stretchBlack, "dark end of the tonal range that becomes black (0-1, below -white)")
+	fs.Float64Var(&opts.White, "white", stretchWhite, "point in the tonal range from which on everything is white (0-1, above -black)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: go run -tags image . image [flags] <input file or directory> [output]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	args = fs.Args()
 	if len(args) < 1 {
-		log.Fatal("Usage: go run main.go image <input> [output]")
+		fs.Usage()
+		os.Exit(2)
+	}
+	if opts.Levels < 2 || opts.Levels > 256 {
+		log.Fatalf("-levels must be between 2 and 256, got %d", opts.Levels)
+	}
+	if opts.Size < 1 {
+		log.Fatalf("-size must be positive, got %d", opts.Size)
+	}
+	var err error
+	if *srcset != "" {
+		if opts.Srcset, err = parseSizes(*srcset); err != nil {
+			log.Fatalf("-srcset: %v", err)
+		}
+	}
+	if opts.Threshold < 0 || opts.Threshold > 255 {
+		log.Fatalf("-threshold must be between 0 and 255, got %d", opts.Threshold)
+	}
+	if opts.Sigma <= 0 || opts.Amount < 0 || opts.Contrast <= 0 {
+		log.Fatalf("-sigma and -contrast must be positive and -amount must not be negative")
+	}
+	if opts.Midpoint < 0 || opts.Midpoint > 1 {
+		log.Fatalf("-midpoint must be between 0 and 1, got %g", opts.Midpoint)
+	}
+	if opts.Black < 0 || opts.White > 1 || opts.Black >= opts.White {
+		log.Fatalf("-black and -white must be between 0 and 1 with -black below -white, got %g and %g", opts.Black, opts.White)
+	}
+	if ditherers[opts.Dither] == nil {
+		log.Fatalf("-dither must be floyd, atkinson, ordered or none, got %q", opts.Dither)
+	}
+	if opts.BG, err = parseHexColor(*bgHex); err != nil {
+		log.Fatal(err)
 	}
 
 	in := args[0]
-	out := path.Join("public", "images", strings.TrimSuffix(filepath.Base(in), filepath.Ext(in))+".png")
-
+	out := *outPath
+	if out == "" && len(args) > 1 {
+		out = args[1]
+	}
 	inStat, err := os.Stat(in)
 	if err != nil {
 		log.Fatal(err)
 	}
-	inSize := inStat.Size()
-
-	f, err := os.Open(in)
+	if inStat.IsDir() {
+		if out == "" {
+			out = filepath.Join(outputDir, "images")
+		}
+		convertImageDir(in, out, opts)
+		return
+	}
+	if out == "" {
+		out = filepath.Join(outputDir, "images", pngName(in))
+	}
+	outputs, inSize, outSize, err := convertImage(in, out, opts)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer f.Close()
+	log.Printf("Input: %s (%.2f MB)", in, float64(inSize)/(1024*1024))
+	log.Printf("Output: %s (%.2f MB)", strings.Join(outputs, ", "), float64(outSize)/(1024*1024))
+	log.Printf("Reduction: %.1f%%", 100.0*(1.0-float64(outSize)/float64(inSize)))
+}
+
+// imageOptions are the settings of one image command run, applied to every
+// converted file.
+type imageOptions struct {
+	BG     color.RGBA
+	Pad    int
+	Size   int
+	Dither string
+	Gray   bool
+	Srcset []int // long edge sizes of the variants to write instead of Size
+	palette
+	pipeline
+}
+
+// imageExtensions are the inputs a directory run picks up.
+var imageExtensions = map[string]bool{".jpg": true, ".jpeg": true, ".png": true}
+
+// pngName is the output file name for input file in.
+func pngName(in string) string {
+	return strings.TrimSuffix(filepath.Base(in), filepath.Ext(in)) + ".png"
+}
 
-	img, _, err := image.Decode(f)
+// convertImageDir converts every image in dir into outDir on all CPU cores.
+// Failing images are logged and skipped, the command exits non-zero at the
+// end if there were any.
+func convertImageDir(dir, outDir string, opts imageOptions) {
+	entries, err := os.ReadDir(dir)
 	if err != nil {
 		log.Fatal(err)
 	}
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && imageExtensions[strings.ToLower(filepath.Ext(e.Name()))] {
+			files = append(files, e.Name())
+		}
+	}
+	if len(files) == 0 {
+		log.Fatalf("no .jpg, .jpeg or .png files in %s", dir)
+	}
 
-	img = resizeLongEdge(img, maxLongEdge)
-	gray := toGrayscale(img)
-	bw := dither(gray)
+	var mu sync.Mutex
+	var totalIn, totalOut int64
+	var converted, failed int
+	var wg sync.WaitGroup
+	jobs := make(chan string)
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range jobs {
+				in, out := filepath.Join(dir, name), filepath.Join(outDir, pngName(name))
+				outputs, inSize, outSize, err := convertImage(in, out, opts)
+				mu.Lock()
+				if err != nil {
+					log.Printf("skipping %s: %v", in, err)
+					failed++
+				} else {
+					log.Printf("%s -> %s (%.2f MB -> %.2f MB)", in, strings.Join(outputs, ", "), float64(inSize)/(1024*1024), float64(outSize)/(1024*1024))
+					totalIn += inSize
+					totalOut += outSize
+					converted++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, name := range files {
+		jobs <- name
+	}
+	close(jobs)
+	wg.Wait()
 
-	o, err := os.Create(out)
+	log.Printf("Converted %d of %d images: %.2f MB -> %.2f MB", converted, len(files), float64(totalIn)/(1024*1024), float64(totalOut)/(1024*1024))
+	if totalIn > 0 {
+		log.Printf("Reduction: %.1f%%", 100.0*(1.0-float64(totalOut)/float64(totalIn)))
+	}
+	if failed > 0 {
+		log.Fatalf("%d image(s) failed", failed)
+	}
+}
+
+// convertImage runs the whole pipeline on one file and writes the result as
+// PNG to out, or with opts.Srcset one variant per size next to it, returning
+// the written files and the sizes of input and output.
+func convertImage(in, out string, opts imageOptions) (outputs []string, inSize, outSize int64, err error) {
+	data, err := os.ReadFile(in)
 	if err != nil {
-		log.Fatal(err)
+		return nil, 0, 0, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("decoding: %w", err)
+	}
+	img = orient(img, exifOrientation(data))
+	if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+		return nil, 0, 0, err
+	}
+
+	if len(opts.Srcset) == 0 {
+		outSize, err = writePNG(out, processImage(img, opts))
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		return []string{out}, int64(len(data)), outSize, nil
+	}
+	long := max(img.Bounds().Dx(), img.Bounds().Dy())
+	for _, size := range opts.Srcset {
+		if size > long {
+			log.Printf("skipping %s at %d pixels, it is only %d pixels large", in, size, long)
+			continue
+		}
+		opts.Size = size
+		file := srcsetVariant(out, size)
+		n, err := writePNG(file, processImage(img, opts))
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		outputs = append(outputs, file)
+		outSize += n
+	}
+	if len(outputs) == 0 {
+		return nil, 0, 0, fmt.Errorf("%d pixels is smaller than every -srcset size", long)
+	}
+	return outputs, int64(len(data)), outSize, nil
+}
+
+// processImage turns a decoded image into the final grayscale or dithered
+// one, resized to opts.Size.
+func processImage(img image.Image, opts imageOptions) image.Image {
+	img = flatten(img, opts.BG)
+	img = resizeLongEdge(img, opts.Size)
+	img = pad(img, opts.Pad, opts.BG)
+	gray := toGrayscale(img, opts.pipeline)
+	if opts.Gray {
+		return gray
+	}
+	return ditherers[opts.Dither](gray, opts.palette)
+}
+
+// srcsetVariant names the variant of out at the given size, name@400w.png
+// for name.png. The Markdown renderer looks for these, see imageSrcset.
+func srcsetVariant(out string, size int) string {
+	return strings.TrimSuffix(out, filepath.Ext(out)) + "@" + strconv.Itoa(size) + "w.png"
+}
+
+// writePNG encodes img to file and returns the size of the file.
+func writePNG(file string, img image.Image) (int64, error) {
+	o, err := os.Create(file)
+	if err != nil {
+		return 0, err
 	}
 	defer o.Close()
 
 	enc := &png.Encoder{CompressionLevel: png.BestCompression}
-	enc.Encode(o, bw)
-	o.Close()
-
-	outStat, err := os.Stat(out)
+	if err := enc.Encode(o, img); err != nil {
+		return 0, err
+	}
+	if err := o.Close(); err != nil {
+		return 0, err
+	}
+	stat, err := os.Stat(file)
 	if err != nil {
-		log.Fatal(err)
+		return 0, err
 	}
-	outSize := outStat.Size()
+	return stat.Size(), nil
+}
 
-	log.Printf("Input: %s (%.2f MB)", in, float64(inSize)/(1024*1024))
-	log.Printf("Output: %s (%.2f MB)", out, float64(outSize)/(1024*1024))
-	log.Printf("Reduction: %.1f%%", 100.0*(1.0-float64(outSize)/float64(inSize)))
+// parseSizes parses a comma separated list of pixel sizes like 200,400,800
+// into ascending order.
+func parseSizes(list string) ([]int, error) {
+	var sizes []int
+	for _, field := range strings.Split(list, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid size %q, expected a positive number of pixels", field)
+		}
+		if !slices.Contains(sizes, n) {
+			sizes = append(sizes, n)
+		}
+	}
+	slices.Sort(sizes)
+	return sizes, nil
+}
+
+func parseHexColor(s string) (color.RGBA, error) {
+	var c color.RGBA
+	s = strings.TrimPrefix(s, "#")
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if _, err := fmt.Sscanf(s, "%02x%02x%02x", &c.R, &c.G, &c.B); err != nil || len(s) != 6 {
+		return c, fmt.Errorf("invalid color %q, expected #rrggbb", s)
+	}
+	c.A = 255
+	return c, nil
+}
+
+// flatten composites img onto a solid background so transparent regions
+// get a defined color before the grayscale conversion.
+func flatten(img image.Image, bg color.Color) *image.RGBA {
+	b := img.Bounds()
+	out := image.NewRGBA(b)
+	draw.Draw(out, b, image.NewUniform(bg), image.Point{}, draw.Src)
+	draw.Draw(out, b, img, b.Min, draw.Over)
+	return out
+}
+
+// pad surrounds img with n pixels of bg on every side.
+func pad(img image.Image, n int, bg color.Color) image.Image {
+	if n <= 0 {
+		return img
+	}
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, b.Dx()+2*n, b.Dy()+2*n))
+	draw.Draw(out, out.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+	draw.Draw(out, image.Rect(n, n, n+b.Dx(), n+b.Dy()), img, b.Min, draw.Src)
+	return out
+}
+
+// pipeline selects and tunes the enhancement steps toGrayscale applies
+// after the luma conversion.
+type pipeline struct {
+	Sharpen  bool
+	Sigma    float64 // unsharp mask blur radius
+	Amount   float64 // unsharp mask strength
+	Sigmoid  bool
+	Contrast float64 // sigmoid steepness
+	Midpoint float64 // sigmoid center, 0-1
+	Stretch  bool
+	Black    float64 // dark end of the tonal range that becomes black, 0-1
+	White    float64 // point in the tonal range from which on all is white, 0-1
 }
 
-func toGrayscale(img image.Image) *image.Gray {
+func toGrayscale(img image.Image, opts pipeline) *image.Gray {
 	b := img.Bounds()
 	g := image.NewGray(b)
 
@@ -88,15 +367,21 @@ func toGrayscale(img image.Image) *image.Gray {
 		}
 	}
 
-	g = unsharp(g, unsharpSigma, unsharpAmount)
-	g = sigmoid(g, sigmoidContrast, sigmoidMidpoint)
-	g = stretch(g, stretchBlack, stretchWhite)
+	if opts.Sharpen {
+		g = unsharp(g, opts.Sigma, opts.Amount)
+	}
+	if opts.Sigmoid {
+		g = sigmoid(g, opts.Contrast, opts.Midpoint)
+	}
+	if opts.Stretch {
+		g = stretch(g, opts.Black, opts.White)
+	}
 	return g
 }
 
 func unsharp(img *image.Gray, sigma, amt float64) *image.Gray {
 	b := img.Bounds()
-	blur := boxBlur(img, int(sigma*2))
+	blur := gaussianBlur(img, sigma)
 	out := image.NewGray(b)
 
 	for y := b.Min.Y; y < b.Max.Y; y++ {
@@ -109,23 +394,52 @@ func unsharp(img *image.Gray, sigma, amt float64) *image.Gray {
 	return out
 }
 
-func boxBlur(img *image.Gray, r int) *image.Gray {
+// gaussianKernel returns the weights of a 1D Gaussian of the given sigma,
+// three sigmas to either side of the center.
+func gaussianKernel(sigma float64) []float64 {
+	r := int(math.Ceil(3 * sigma))
+	if r < 1 {
+		r = 1
+	}
+	k := make([]float64, 2*r+1)
+	for i := range k {
+		d := float64(i - r)
+		k[i] = math.Exp(-d * d / (2 * sigma * sigma))
+	}
+	return k
+}
+
+// gaussianBlur blurs img in two separable passes, horizontal then vertical,
+// so the cost grows with the radius instead of its square. Weights falling
+// outside the image are left out and the rest renormalized.
+func gaussianBlur(img *image.Gray, sigma float64) *image.Gray {
 	b := img.Bounds()
-	out := image.NewGray(b)
+	w, h := b.Dx(), b.Dy()
+	k := gaussianKernel(sigma)
+	r := len(k) / 2
 
-	for y := b.Min.Y; y < b.Max.Y; y++ {
-		for x := b.Min.X; x < b.Max.X; x++ {
+	tmp := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		row := img.Pix[img.PixOffset(b.Min.X, b.Min.Y+y):]
+		for x := 0; x < w; x++ {
 			var sum, n float64
-			for dy := -r; dy <= r; dy++ {
-				for dx := -r; dx <= r; dx++ {
-					nx, ny := x+dx, y+dy
-					if nx >= b.Min.X && nx < b.Max.X && ny >= b.Min.Y && ny < b.Max.Y {
-						sum += float64(img.GrayAt(nx, ny).Y)
-						n++
-					}
-				}
+			for i := max(-r, -x); i <= r && x+i < w; i++ {
+				sum += k[i+r] * float64(row[x+i])
+				n += k[i+r]
 			}
-			out.SetGray(x, y, color.Gray{Y: uint8(sum / n)})
+			tmp[y*w+x] = sum / n
+		}
+	}
+
+	out := image.NewGray(b)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var sum, n float64
+			for i := max(-r, -y); i <= r && y+i < h; i++ {
+				sum += k[i+r] * tmp[(y+i)*w+x]
+				n += k[i+r]
+			}
+			out.Pix[y*out.Stride+x] = clamp(math.Round(sum / n))
 		}
 	}
 	return out
@@ -188,9 +502,15 @@ func clamp(v float64) uint8 {
 	return uint8(v)
 }
 
+// resizeLongEdge scales img down so its longer edge is maxLongEdge pixels,
+// interpolating bilinearly between the four nearest source pixels. Images
+// that already fit are returned as they are, never upscaled.
 func resizeLongEdge(img image.Image, maxLongEdge int) image.Image {
 	b := img.Bounds()
 	w, h := b.Dx(), b.Dy()
+	if w <= maxLongEdge && h <= maxLongEdge {
+		return img
+	}
 
 	var nw, nh int
 	if w > h {
@@ -200,55 +520,163 @@ func resizeLongEdge(img image.Image, maxLongEdge int) image.Image {
 		nh = maxLongEdge
 		nw = int(float64(w) * float64(maxLongEdge) / float64(h))
 	}
+	nw, nh = max(nw, 1), max(nh, 1)
 
 	out := image.NewRGBA(image.Rect(0, 0, nw, nh))
 
 	for y := 0; y < nh; y++ {
+		y0, y1, fy := bilinearSpan(y, nh, h)
 		for x := 0; x < nw; x++ {
-			sx := int(float64(x) * float64(w) / float64(nw))
-			sy := int(float64(y) * float64(h) / float64(nh))
-			out.Set(x, y, img.At(sx, sy))
+			x0, x1, fx := bilinearSpan(x, nw, w)
+			var c [4]float64
+			add := func(px, py int, weight float64) {
+				r, g, bl, a := img.At(b.Min.X+px, b.Min.Y+py).RGBA()
+				c[0] += weight * float64(r)
+				c[1] += weight * float64(g)
+				c[2] += weight * float64(bl)
+				c[3] += weight * float64(a)
+			}
+			add(x0, y0, (1-fx)*(1-fy))
+			add(x1, y0, fx*(1-fy))
+			add(x0, y1, (1-fx)*fy)
+			add(x1, y1, fx*fy)
+			out.SetRGBA(x, y, color.RGBA{clamp(c[0] / 257), clamp(c[1] / 257), clamp(c[2] / 257), clamp(c[3] / 257)})
 		}
 	}
 
 	return out
 }
 
-func dither(img *image.Gray) *image.Gray {
+// bilinearSpan maps output pixel i of n onto a source of size src, returning
+// the two neighboring source pixels and the weight of the second. Pixel
+// centers are aligned and both neighbors stay inside the source.
+func bilinearSpan(i, n, src int) (i0, i1 int, frac float64) {
+	s := (float64(i)+0.5)*float64(src)/float64(n) - 0.5
+	s = math.Max(0, math.Min(s, float64(src-1)))
+	i0 = int(s)
+	i1 = min(i0+1, src-1)
+	return i0, i1, s - float64(i0)
+}
+
+// palette is the set of gray values the ditherers reduce an image to.
+type palette struct {
+	Levels    int // evenly spaced gray values, 2 is black and white
+	Threshold int // the value above which a pixel becomes white with 2 levels
+}
+
+// quantize maps v to the nearest of p's gray values. Two levels use the
+// threshold instead of the midpoint.
+func (p palette) quantize(v uint8) uint8 {
+	if p.Levels <= 2 {
+		if int(v) > p.Threshold {
+			return 255
+		}
+		return 0
+	}
+	step := 255.0 / float64(p.Levels-1)
+	return uint8(math.Round(float64(v)/step) * step)
+}
+
+// ditherers are the error diffusion and threshold methods selectable with
+// -dither. Each reduces img to the gray values of p.
+var ditherers = map[string]func(img *image.Gray, p palette) *image.Gray{
+	"floyd":    ditherFloyd,
+	"atkinson": ditherAtkinson,
+	"ordered":  ditherOrdered,
+	"none":     ditherNone,
+}
+
+// diffuse adds the quantization error e to the pixel at x, y if it is
+// inside img.
+func diffuse(img *image.Gray, x, y, e int) {
+	if !(image.Point{x, y}.In(img.Bounds())) {
+		return
+	}
+	img.SetGray(x, y, color.Gray{Y: clamp(float64(int(img.GrayAt(x, y).Y) + e))})
+}
+
+// ditherFloyd uses Floyd–Steinberg error diffusion, spreading the whole
+// error to the right and the row below. It modifies img.
+func ditherFloyd(img *image.Gray, p palette) *image.Gray {
 	b := img.Bounds()
 	out := image.NewGray(b)
 
 	for y := b.Min.Y; y < b.Max.Y; y++ {
 		for x := b.Min.X; x < b.Max.X; x++ {
 			old := img.GrayAt(x, y).Y
-			var new uint8
-			if old > ditherThreshold {
-				new = 255
-			} else {
-				new = 0
-			}
+			new := p.quantize(old)
 			out.SetGray(x, y, color.Gray{Y: new})
 
 			err := int(old) - int(new)
+			diffuse(img, x+1, y, err*7/16)
+			diffuse(img, x-1, y+1, err*3/16)
+			diffuse(img, x, y+1, err*5/16)
+			diffuse(img, x+1, y+1, err*1/16)
+		}
+	}
+	return out
+}
 
-			if x+1 < b.Max.X {
-				v := int(img.GrayAt(x+1, y).Y) + err*7/16
-				img.SetGray(x+1, y, color.Gray{Y: clamp(float64(v))})
-			}
-			if y+1 < b.Max.Y {
-				if x > b.Min.X {
-					v := int(img.GrayAt(x-1, y+1).Y) + err*3/16
-					img.SetGray(x-1, y+1, color.Gray{Y: clamp(float64(v))})
-				}
-				v := int(img.GrayAt(x, y+1).Y) + err*5/16
-				img.SetGray(x, y+1, color.Gray{Y: clamp(float64(v))})
-				if x+1 < b.Max.X {
-					v := int(img.GrayAt(x+1, y+1).Y) + err*1/16
-					img.SetGray(x+1, y+1, color.Gray{Y: clamp(float64(v))})
-				}
-			}
+// ditherAtkinson uses the error diffusion of the classic Macintosh: only
+// three quarters of the error are spread, over a wider area, which keeps
+// highlights and shadows clean at the cost of some detail. It modifies img.
+func ditherAtkinson(img *image.Gray, p palette) *image.Gray {
+	b := img.Bounds()
+	out := image.NewGray(b)
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			old := img.GrayAt(x, y).Y
+			new := p.quantize(old)
+			out.SetGray(x, y, color.Gray{Y: new})
+
+			e := (int(old) - int(new)) / 8
+			diffuse(img, x+1, y, e)
+			diffuse(img, x+2, y, e)
+			diffuse(img, x-1, y+1, e)
+			diffuse(img, x, y+1, e)
+			diffuse(img, x+1, y+1, e)
+			diffuse(img, x, y+2, e)
 		}
 	}
+	return out
+}
 
+// bayer4 is the 4x4 Bayer threshold matrix used by ditherOrdered.
+var bayer4 = [4][4]float64{
+	{0, 8, 2, 10},
+	{12, 4, 14, 6},
+	{3, 11, 1, 9},
+	{15, 7, 13, 5},
+}
+
+// ditherOrdered offsets every pixel by its position in a Bayer matrix before
+// quantizing, giving a regular crosshatch pattern instead of noise.
+func ditherOrdered(img *image.Gray, p palette) *image.Gray {
+	b := img.Bounds()
+	out := image.NewGray(b)
+	step := 255.0 / float64(p.Levels-1)
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			offset := ((bayer4[y&3][x&3]+0.5)/16 - 0.5) * step
+			v := clamp(float64(img.GrayAt(x, y).Y) + offset)
+			out.SetGray(x, y, color.Gray{Y: p.quantize(v)})
+		}
+	}
+	return out
+}
+
+// ditherNone quantizes every pixel on its own, a plain threshold for two
+// levels.
+func ditherNone(img *image.Gray, p palette) *image.Gray {
+	b := img.Bounds()
+	out := image.NewGray(b)
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			out.SetGray(x, y, color.Gray{Y: p.quantize(img.GrayAt(x, y).Y)})
+		}
+	}
 	return out
 }
@@ -0,0 +1,150 @@
+//go:build image
+
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFlattenTransparency(t *testing.T) {
+	// left half transparent, right half opaque black
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for y := 0; y < 2; y++ {
+		for x := 2; x < 4; x++ {
+			img.Set(x, y, color.NRGBA{A: 255})
+		}
+	}
+	tests := []struct {
+		name string
+		bg   string
+		want color.RGBA
+	}{
+		{"white", "#ffffff", color.RGBA{255, 255, 255, 255}},
+		{"custom", "#336699", color.RGBA{0x33, 0x66, 0x99, 255}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bg, err := parseHexColor(tt.bg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := flatten(img, bg)
+			if got := out.RGBAAt(0, 0); got != tt.want {
+				t.Errorf("transparent pixel = %v, want %v", got, tt.want)
+			}
+			if got := out.RGBAAt(3, 1); got != (color.RGBA{0, 0, 0, 255}) {
+				t.Errorf("opaque pixel = %v, want black", got)
+			}
+		})
+	}
+}
+
+func TestPad(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 3, 2))
+	bg := color.RGBA{255, 255, 255, 255}
+	out := pad(img, 2, bg)
+	if got := out.Bounds(); got != image.Rect(0, 0, 7, 6) {
+		t.Fatalf("padded bounds %v, want 7x6", got)
+	}
+	if r, _, _, _ := out.At(0, 0).RGBA(); r != 0xffff {
+		t.Error("padding is not the background color")
+	}
+	if r, _, _, _ := out.At(2, 2).RGBA(); r != 0 {
+		t.Error("image is not inside the padding")
+	}
+	if pad(img, 0, bg) != image.Image(img) {
+		t.Error("zero padding should return the image as is")
+	}
+}
+
+func TestPipelineStages(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 8, 8))
+	for i := range src.Pix {
+		src.Pix[i] = uint8(i * 4)
+	}
+	full := pipeline{Sharpen: true, Sigma: unsharpSigma, Amount: unsharpAmount, Sigmoid: true, Contrast: sigmoidContrast, Midpoint: sigmoidMidpoint, Stretch: true, Black: stretchBlack, White: stretchWhite}
+	tests := []struct {
+		name    string
+		disable func(*pipeline)
+		want    func(*image.Gray) *image.Gray
+	}{
+		{"all", func(p *pipeline) {}, func(g *image.Gray) *image.Gray {
+			return stretch(sigmoid(unsharp(g, unsharpSigma, unsharpAmount), sigmoidContrast, sigmoidMidpoint), stretchBlack, stretchWhite)
+		}},
+		{"no sharpen", func(p *pipeline) { p.Sharpen = false }, func(g *image.Gray) *image.Gray {
+			return stretch(sigmoid(g, sigmoidContrast, sigmoidMidpoint), stretchBlack, stretchWhite)
+		}},
+		{"no sigmoid", func(p *pipeline) { p.Sigmoid = false }, func(g *image.Gray) *image.Gray {
+			return stretch(unsharp(g, unsharpSigma, unsharpAmount), stretchBlack, stretchWhite)
+		}},
+		{"no stretch", func(p *pipeline) { p.Stretch = false }, func(g *image.Gray) *image.Gray {
+			return sigmoid(unsharp(g, unsharpSigma, unsharpAmount), sigmoidContrast, sigmoidMidpoint)
+		}},
+		{"none", func(p *pipeline) { p.Sharpen, p.Sigmoid, p.Stretch = false, false, false }, func(g *image.Gray) *image.Gray {
+			return g
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := full
+			tt.disable(&opts)
+			got := toGrayscale(src, opts)
+			if want := tt.want(src); !bytes.Equal(got.Pix, want.Pix) {
+				t.Errorf("got %v, want %v", got.Pix, want.Pix)
+			}
+		})
+	}
+}
+
+func TestDitherPalette(t *testing.T) {
+	// a horizontal gradient over all gray values
+	src := image.NewGray(image.Rect(0, 0, 256, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 256; x++ {
+			src.SetGray(x, y, color.Gray{Y: uint8(x)})
+		}
+	}
+	tests := []struct {
+		levels int
+		want   []uint8
+	}{
+		{2, []uint8{0, 255}},
+		{4, []uint8{0, 85, 170, 255}},
+		{16, nil},
+	}
+	for _, tt := range tests {
+		for name, dither := range ditherers {
+			img := image.NewGray(src.Bounds())
+			copy(img.Pix, src.Pix)
+			out := dither(img, palette{Levels: tt.levels, Threshold: ditherThreshold})
+			seen := map[uint8]bool{}
+			for _, v := range out.Pix {
+				seen[v] = true
+			}
+			if len(seen) != tt.levels {
+				t.Errorf("%s with %d levels: %d distinct values", name, tt.levels, len(seen))
+			}
+			for _, v := range tt.want {
+				if !seen[v] {
+					t.Errorf("%s with %d levels: gray %d missing", name, tt.levels, v)
+				}
+			}
+		}
+	}
+}
+
+func TestSrcsetVariantName(t *testing.T) {
+	got := srcsetVariant(filepath.Join("public", "images", "chart.png"), 400)
+	if want := filepath.Join("public", "images", "chart@400w.png"); got != want {
+		t.Errorf("srcsetVariant = %q, want %q", got, want)
+	}
+	// imageSrcset has to recognize what image -srcset writes
+	if suffix := strings.TrimPrefix(filepath.Base(got), "chart"); !srcsetVariantRe.MatchString(suffix) {
+		t.Errorf("%q is not taken for a variant", got)
+	}
+}
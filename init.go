@@ -0,0 +1,86 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// scaffold holds the starter templates, which are neutral versions of the
+// site's own, and its style.css.
+//
+//go:embed scaffold/*.html style.css
+var scaffold embed.FS
+
+const samplePost = `# Hello world
+
+This is your first post. Edit or delete ` + "`articles/%s-hello-world.md`" + ` and run the build again.
+
+## Next steps
+
+- Write posts as ` + "`YYYY-MM-DD-title.md`" + ` files in ` + "`articles/`" + `
+- Adjust ` + "`index.html`" + `, ` + "`article.html`" + ` and ` + "`style.css`" + ` to your liking
+`
+
+func runInitCommand(args []string) {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	if err := initSite(dir); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// initSite writes a minimal working site into dir: the scaffold templates,
+// style.css, a config.json without analytics and a sample post. It refuses
+// to overwrite any existing file.
+func initSite(dir string) error {
+	today := time.Now().Format("2006-01-02")
+	settings, _ := json.MarshalIndent(map[string]any{
+		"Title":     "My blog",
+		"Slogan":    "",
+		"BaseURL":   "http://localhost:8080",
+		"Links":     map[string]string{},
+		"Projects":  map[string]string{},
+		"Tools":     []Tool{},
+		"Analytics": map[string]string{}, // no tracking script
+	}, "", "  ")
+	files := map[string][]byte{
+		filepath.Join("articles", today+"-hello-world.md"): []byte(fmt.Sprintf(samplePost, today)),
+		configFile: append(settings, '\n'),
+	}
+	fs.WalkDir(scaffold, ".", func(name string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			data, _ := scaffold.ReadFile(name)
+			files[path.Base(name)] = data
+		}
+		return err
+	})
+
+	var names []string
+	for name := range files {
+		names = append(names, name)
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return fmt.Errorf("refusing to overwrite existing %s", name)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "articles"), 0755); err != nil {
+		return err
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), files[name], 0644); err != nil {
+			return err
+		}
+		buildLog.Printf("", "created: %s", filepath.Join(dir, name))
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInitSiteBuilds(t *testing.T) {
+	testSite(t, nil)
+	config = compiledConfig
+	if err := initSite("."); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"index.html", "article.html", "style.css", configFile, filepath.Join("articles", time.Now().Format("2006-01-02")+"-hello-world.md")} {
+		if !fileExists(name) {
+			t.Errorf("init did not create %s", name)
+		}
+	}
+	if err := loadConfigFile(configFile, &config); err != nil {
+		t.Fatal(err)
+	}
+	if err := buildSite(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(readOutput(t, "index.html"), "Hello world") {
+		t.Error("index.html does not list the sample post")
+	}
+	// nothing of the nobloat site, its tracking script included, may leak into a new one
+	filepath.WalkDir(outputDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, _ := os.ReadFile(path)
+		for _, s := range []string{"nobloat", "plausible", "<script defer"} {
+			if strings.Contains(strings.ToLower(string(data)), s) {
+				t.Errorf("%s contains %q", path, s)
+			}
+		}
+		return nil
+	})
+}
+
+func TestInitSiteRefusesToOverwrite(t *testing.T) {
+	testSite(t, map[string]string{"style.css": "body {}"})
+	if err := initSite("."); err == nil {
+		t.Fatal("init overwrote an existing style.css")
+	}
+	if data, _ := os.ReadFile("style.css"); string(data) != "body {}" {
+		t.Errorf("style.css = %q", data)
+	}
+}
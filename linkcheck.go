@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	linkCacheFile    = ".linkcache.json"
+	linkCacheTTL     = 7 * 24 * time.Hour
+	linkCheckWorkers = 8
+	linkCheckTimeout = 10 * time.Second
+)
+
+var linkAttrRe = regexp.MustCompile(`(?:href|src|srcset)="([^"]+)"`)
+
+// checkExternal enables checkExternalLinks as part of every build.
+var checkExternal bool
+
+// extractLinks returns the href, src and srcset targets of rendered HTML.
+func extractLinks(content string) []string {
+	var links []string
+	for _, m := range linkAttrRe.FindAllStringSubmatch(content, -1) {
+		if strings.HasPrefix(m[0], "srcset=") {
+			links = append(links, srcsetURLs(html.UnescapeString(m[1]))...)
+		} else {
+			links = append(links, html.UnescapeString(m[1]))
+		}
+	}
+	return links
+}
+
+// checkExternalLinks HEAD-requests every http(s) link in the posts with
+// bounded concurrency and reports dead or redirecting ones. URLs verified
+// within linkCacheTTL are skipped, see linkCacheFile.
+func checkExternalLinks(posts []Post) {
+	cache := map[string]time.Time{}
+	if data, err := os.ReadFile(linkCacheFile); err == nil {
+		_ = json.Unmarshal(data, &cache)
+	}
+
+	usedBy := map[string][]string{}
+	for _, p := range posts {
+		for _, link := range extractLinks(string(p.Content)) {
+			if !strings.HasPrefix(link, "http://") && !strings.HasPrefix(link, "https://") {
+				continue
+			}
+			if time.Since(cache[link]) < linkCacheTTL {
+				continue
+			}
+			usedBy[link] = append(usedBy[link], p.Slug)
+		}
+	}
+	var urls []string
+	for u := range usedBy {
+		urls = append(urls, u)
+	}
+	sort.Strings(urls)
+
+	client := &http.Client{
+		Timeout: linkCheckTimeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	jobs := make(chan string)
+	for i := 0; i < linkCheckWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for u := range jobs {
+				status, location, err := checkURL(client, u)
+				mu.Lock()
+				switch {
+				case err != nil:
+					for _, slug := range usedBy[u] {
+						buildLog.Warnf(slug, "dead link %s: %v", u, err)
+					}
+				case status >= 300 && status < 400:
+					for _, slug := range usedBy[u] {
+						buildLog.Warnf(slug, "link %s redirects (%d) to %s", u, status, location)
+					}
+				case status >= 400:
+					for _, slug := range usedBy[u] {
+						buildLog.Warnf(slug, "dead link %s: %d %s", u, status, http.StatusText(status))
+					}
+				default:
+					cache[u] = time.Now()
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, u := range urls {
+		jobs <- u
+	}
+	close(jobs)
+	wg.Wait()
+
+	buildLog.Printf("", "checked %d external links", len(urls))
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err == nil {
+		err = os.WriteFile(linkCacheFile, data, 0644)
+	}
+	if err != nil {
+		// not fatal, the links are just checked again next time
+		buildLog.Warnf("links", "writing %s: %v", linkCacheFile, err)
+	}
+}
+
+// checkURL sends a HEAD request, falling back to GET for servers that don't
+// support HEAD.
+func checkURL(client *http.Client, u string) (status int, location string, err error) {
+	resp, err := client.Head(u)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp.Body.Close()
+		resp, err = client.Get(u)
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, resp.Header.Get("Location"), nil
+}
+
+// strict turns broken internal links into build errors instead of warnings.
+var strict bool
+
+// checkInternalLinks verifies that every same-site link and image in the
+// posts points to a file of this build, or for non-pages to any file under
+// the output directory. Pages left over from renamed posts don't count.
+func checkInternalLinks(posts []Post) error {
+	manifest.Lock()
+	defer manifest.Unlock()
+	var errs []error
+	for _, p := range posts {
+		for _, link := range extractLinks(string(p.Content)) {
+			target, ok := internalTarget(link, path.Dir(p.URL))
+			if !ok || manifest.files[target] != "" || manifest.files[target+"/index.html"] != "" {
+				continue
+			}
+			if info, err := os.Stat(filepath.Join(outputDir, filepath.FromSlash(target))); err == nil && !info.IsDir() && path.Ext(target) != ".html" {
+				continue
+			}
+			if strict {
+				errs = append(errs, fmt.Errorf("%s: broken link %s", p.Slug, link))
+			} else {
+				buildLog.Warnf(p.Slug, "broken link %s", link)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// internalTarget resolves a link found on a page in directory dir to a file
+// path below the output directory. ok is false for links to other sites,
+// other schemes and fragments of the same page.
+func internalTarget(link, dir string) (target string, ok bool) {
+	if config.BaseURL != "" && strings.HasPrefix(link, config.BaseURL+"/") {
+		link = strings.TrimPrefix(link, config.BaseURL)
+	} else if u, err := url.Parse(link); err != nil || u.Scheme != "" || u.Host != "" {
+		return "", false
+	}
+	if i := strings.IndexAny(link, "?#"); i >= 0 {
+		link = link[:i]
+	}
+	if link == "" {
+		return "", false
+	}
+	if unescaped, err := url.PathUnescape(link); err == nil {
+		link = unescaped
+	}
+	if strings.HasPrefix(link, "/") {
+		if u, err := url.Parse(config.BaseURL); err == nil && u.Path != "" && u.Path != "/" {
+			link = strings.TrimPrefix(link, strings.TrimSuffix(u.Path, "/"))
+		}
+		target = path.Clean(link)[1:]
+	} else {
+		target = path.Join(dir, link)
+	}
+	if strings.HasSuffix(link, "/") || target == "" {
+		target = path.Join(target, "index.html")
+	}
+	return target, true
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCheckExternalLinks(t *testing.T) {
+	testSite(t, nil)
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Path {
+		case "/ok":
+		case "/moved":
+			http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+		case "/no-head":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	posts := []Post{
+		{Slug: "first", Content: template.HTML(`<a href="` + srv.URL + `/ok">a</a> <a href="` + srv.URL + `/missing">b</a>`)},
+		{Slug: "second", Content: template.HTML(`<a href="` + srv.URL + `/moved">c</a> <img src="` + srv.URL + `/no-head"> <a href="../local.html">d</a>`)},
+	}
+
+	var log bytes.Buffer
+	buildLog = &logger{out: &log, err: &log}
+	checkExternalLinks(posts)
+	tests := []struct {
+		want   string
+		report bool
+	}{
+		{"[first] dead link " + srv.URL + "/missing: 404 Not Found", true},
+		{"[second] link " + srv.URL + "/moved redirects (301) to /ok", true},
+		{srv.URL + "/ok", false},
+		{srv.URL + "/no-head", false},
+		{"local.html", false},
+	}
+	for _, tt := range tests {
+		if got := strings.Contains(log.String(), tt.want); got != tt.report {
+			t.Errorf("reported %q: %v, want %v\n%s", tt.want, got, tt.report, log.String())
+		}
+	}
+
+	// working links are cached, broken ones are checked again
+	requests.Store(0)
+	checkExternalLinks(posts)
+	if got := requests.Load(); got != 2 {
+		t.Errorf("second run sent %d requests, want 2 for the broken links only", got)
+	}
+}
+
+func TestLinkCacheWriteError(t *testing.T) {
+	testSite(t, nil)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	// a directory in the way of the cache file
+	if err := os.Mkdir(linkCacheFile, 0755); err != nil {
+		t.Fatal(err)
+	}
+	var log bytes.Buffer
+	buildLog = &logger{out: &log, err: &log}
+	checkExternalLinks([]Post{{Slug: "post", Content: template.HTML(`<a href="` + srv.URL + `/ok">a</a>`)}})
+	if !strings.Contains(log.String(), "writing "+linkCacheFile) {
+		t.Errorf("cache write failure not reported:\n%s", log.String())
+	}
+}
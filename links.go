@@ -0,0 +1,99 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// [label]: url "optional title", the title may also use '' or ()
+	linkRefDefRe = regexp.MustCompile(`^\[([^\]^][^\]]*)\]:\s*<?([^\s>]+)>?(?:\s+["'(](.*)["')])?\s*$`)
+	// ![alt][label], [text][label], [text][] and the shortcut [label]
+	linkRefUseRe = regexp.MustCompile(`(!?)\[([^\]]*)\](?:\[([^\]]*)\])?`)
+)
+
+type linkRef struct {
+	url   string
+	title string
+}
+
+// linkRefs resolves reference-style links against the "[label]: url"
+// definitions of a document.
+type linkRefs struct {
+	defs   map[string]linkRef // normalized label -> target
+	source string             // log context for undefined labels
+}
+
+// collectLinkRefs removes reference definitions outside code fences from
+// lines. Labels are case-insensitive, the first definition of a label wins.
+func collectLinkRefs(lines []string, source string) ([]string, *linkRefs) {
+	refs := &linkRefs{defs: map[string]linkRef{}, source: source}
+	var kept []string
+	fence := ""
+	for _, raw := range lines {
+		line := strings.TrimSpace(raw)
+		fenceLine(&fence, line)
+		if m := linkRefDefRe.FindStringSubmatch(line); m != nil && fence == "" {
+			if label := normalizeLabel(m[1]); refs.defs[label] == (linkRef{}) {
+				refs.defs[label] = linkRef{url: m[2], title: m[3]}
+			}
+			continue
+		}
+		kept = append(kept, raw)
+	}
+	return kept, refs
+}
+
+func normalizeLabel(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
+
+// link rewrites reference-style links in a markdown line to inline links for
+// formatInline. Code spans are left alone. Undefined [text][label] references
+// stay literal with a warning; an undefined shortcut [label] is just text.
+func (r *linkRefs) link(line string) string {
+	var out strings.Builder
+	last := 0
+	for _, span := range codeRe.FindAllStringIndex(line, -1) {
+		out.WriteString(r.linkText(line[last:span[0]]))
+		out.WriteString(line[span[0]:span[1]])
+		last = span[1]
+	}
+	out.WriteString(r.linkText(line[last:]))
+	return out.String()
+}
+
+func (r *linkRefs) linkText(text string) string {
+	var out strings.Builder
+	last := 0
+	for _, m := range linkRefUseRe.FindAllStringSubmatchIndex(text, -1) {
+		out.WriteString(text[last:m[0]])
+		last = m[1]
+		match := text[m[0]:m[1]]
+		bang, label := text[m[2]:m[3]], text[m[4]:m[5]]
+		full := m[6] >= 0
+		if full && m[7] > m[6] {
+			label = text[m[6]:m[7]]
+		}
+		// inline links and footnotes are handled elsewhere
+		if (!full && m[1] < len(text) && text[m[1]] == '(') || strings.HasPrefix(text[m[4]:m[5]], "^") {
+			out.WriteString(match)
+			continue
+		}
+		ref, ok := r.defs[normalizeLabel(label)]
+		if !ok {
+			if full {
+				buildLog.Warnf(r.source, "undefined link reference [%s]", label)
+			}
+			out.WriteString(match)
+			continue
+		}
+		out.WriteString(bang + "[" + text[m[4]:m[5]] + "](" + ref.url)
+		if ref.title != "" {
+			out.WriteString(` "` + ref.title + `"`)
+		}
+		out.WriteString(")")
+	}
+	out.WriteString(text[last:])
+	return out.String()
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// logger writes whole lines under a mutex so output from concurrent builds
+// doesn't interleave. Messages about a post carry its file name as context.
+type logger struct {
+	mu  sync.Mutex
+	out io.Writer
+	err io.Writer
+}
+
+var buildLog = &logger{out: os.Stdout, err: os.Stderr}
+
+func (l *logger) write(w io.Writer, context, prefix, format string, args ...any) {
+	if context != "" {
+		prefix += "[" + context + "] "
+	}
+	msg := prefix + fmt.Sprintf(format, args...)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintln(w, msg)
+}
+
+// Printf logs progress to stdout. context may be empty.
+func (l *logger) Printf(context, format string, args ...any) {
+	l.write(l.out, context, "", format, args...)
+}
+
+// Warnf logs a problem to stderr. context may be empty.
+func (l *logger) Warnf(context, format string, args ...any) {
+	l.write(l.err, context, "Warning: ", format, args...)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// chunkedWriter stores every call byte by byte, so writes that aren't
+// synchronized by the logger interleave.
+type chunkedWriter struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (w *chunkedWriter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		w.mu.Lock()
+		w.buf.WriteByte(b)
+		w.mu.Unlock()
+	}
+	return len(p), nil
+}
+
+func TestLoggerConcurrent(t *testing.T) {
+	var out, errOut chunkedWriter
+	l := &logger{out: &out, err: &errOut}
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 50 {
+				l.Printf(fmt.Sprintf("post-%d.md", i), "line %d of %d", j, i)
+				l.Warnf(fmt.Sprintf("post-%d.md", i), "warning %d", j)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, tt := range []struct {
+		name   string
+		output string
+		line   *regexp.Regexp
+	}{
+		{"stdout", out.buf.String(), regexp.MustCompile(`^\[post-\d+\.md\] line \d+ of \d+$`)},
+		{"stderr", errOut.buf.String(), regexp.MustCompile(`^Warning: \[post-\d+\.md\] warning \d+$`)},
+	} {
+		lines := strings.Split(strings.TrimSuffix(tt.output, "\n"), "\n")
+		if len(lines) != 20*50 {
+			t.Fatalf("%s: %d lines, want %d", tt.name, len(lines), 20*50)
+		}
+		for _, line := range lines {
+			if !tt.line.MatchString(line) {
+				t.Errorf("%s: corrupted line %q", tt.name, line)
+			}
+		}
+	}
+}
@@ -2,27 +2,65 @@ package main
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"flag"
 	"fmt"
 	"html"
 	"html/template"
+	"io/fs"
 	"log"
+	"math"
+	"mime"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unicode"
+	"unicode/utf8"
 )
 
 type Post struct {
-	Title   string
-	Slug    string
-	Date    time.Time
-	Content template.HTML
-	Excerpt string
+	Title       string
+	Slug        string
+	Source      string // file name in the articles directory
+	URL         string // page path below the site root, see permalink
+	Date        time.Time
+	Updated     time.Time // front matter "updated", defaults to Date
+	Content     template.HTML
+	Excerpt     string        // plain text teaser for feeds and meta descriptions
+	ExcerptHTML template.HTML // rendered teaser for listings, links relative to the site root
+	Cover       string        // absolute URL of the front matter "cover" image, given relative to the post's page
+	Author      string        // front matter "author", defaults to config.Author
+	Kind        string        // "article" (default), "note" or "link"
+	LinkURL     string        // outbound target of a "link" post
+	LayoutClass string        // config.ArticleClass plus the front matter "layout"
+	Section     string        // front matter "section", defaults to Kind
+	ChangeFreq  string        // sitemap <changefreq>, front matter "changefreq"
+	Priority    string        // sitemap <priority>, front matter "priority"
+	Tags        []string      // front matter "tags"
+	Draft       bool          // front matter "draft"
+	WordCount   int
+	ReadingTime int       // minutes
+	Headings    []Heading // h2 to h6 anchors in document order
+	TOC         []Heading // Headings down to config.TOCMaxDepth
+}
+
+// Heading is an anchored section heading of a post, for tables of contents
+// and reading progress indicators.
+type Heading struct {
+	Level int
+	ID    string
+	Text  string // plain text
 }
 
 type Tool struct {
@@ -31,6 +69,15 @@ type Tool struct {
 	URL         string
 }
 
+// Analytics configures an optional tracking script. Leave it empty to ship
+// pages without any third-party JavaScript.
+type Analytics struct {
+	Provider  string // "plausible"
+	SiteID    string // e.g. the plausible data-domain
+	ScriptURL string // overrides the provider default, e.g. for self-hosting
+	Snippet   string // raw HTML used instead of Provider
+}
+
 type Config struct {
 	Title    string
 	Slogan   string
@@ -38,6 +85,38 @@ type Config struct {
 	Links    map[string]string
 	Projects map[string]string
 	Tools    []Tool
+
+	Author          string   // site author, listed in humans.txt
+	Credits         []string // additional humans.txt "THANKS" lines
+	SecurityContact string   // mailto: or https: contact for security.txt
+	SecurityExpires string   // YYYY-MM-DD, required by security.txt
+
+	Analytics Analytics
+
+	PostsPerPage    int    // posts per index page, the rest go to page/2.html, page/3.html, ...; 0 or less for a single page
+	IndexColumns    int    // split the index listing into this many balanced columns, 0 or 1 keeps a single list
+	ListingSort     string // "date" (default) or "updated"
+	UpdatesAll      bool   // list never updated posts on updates.html as well
+	KeepLineEndings bool   // write text outputs verbatim instead of normalizing to LF with one trailing newline
+	ExcerptMode     string // "paragraph" (default) or "sentences:N" for the first N sentences as plain text
+	ExcerptFallback string // teaser for posts without any prose, "title" uses the post title
+	StripTitle      bool   // leave the leading "# Title" out of the content, for templates that show .Title themselves
+	ArticleClass    string // base CSS class of every <article>, extended by a post's "layout"
+	Admonitions     bool   // render "!!! kind Title" blocks followed by indented content as callout boxes
+	InlineCodeClass string // CSS class of inline <code> elements, e.g. "inline-code", empty for none
+	TOCMaxDepth     int    // heading levels in the table of contents, 1 for "##" only, 0 defaults to 2 ("##" and "###")
+	InlineCSS       bool   // put style.css into a <style> element of every page instead of linking it
+	AMP             bool   // also write an AMP version of every post to articles/<slug>.amp.html using amp.html
+
+	PermalinkPattern string // page URL of posts with :year, :month, :day and :slug, e.g. "/:year/:month/:slug/" for directories; empty for "/articles/:slug.html"
+
+	SitemapSections bool // write a sitemap-<section>.xml per post section and make sitemap.xml their index
+
+	FeedSort           string // "published" (default) or "updated" to resurface edited posts, orders entries and dates the feeds
+	FeedReadingTime    bool   // emit <blog:readingTime> and <blog:wordCount> in feed entries
+	FeedMaxItems       int    // newest posts per feed, 0 or less for all; the sitemap always lists every post
+	FullContentFeed    bool   // put the whole rendered post into the Atom <content> instead of the excerpt
+	CodeWordsPerMinute int    // reading speed for fenced code, 0 leaves code out of the reading time
 }
 
 func sanitizeAnchor(input string) string {
@@ -55,123 +134,993 @@ func sanitizeAnchor(input string) string {
 	return strings.ToLower(out.String())
 }
 
-func buildSite() {
-	posts := loadPosts("articles")
-	os.MkdirAll("public", 0755)
-	os.MkdirAll("public/articles", 0755)
-	copyStaticAssets()
-	generateIndex(posts)
-	generatePosts(posts)
-	generateSitemap(posts)
-	generateFeed(posts)
-	fmt.Println("Build complete.")
+// lastPostsHash remembers the post set the feed and sitemap were last
+// generated from, so watch mode rebuilds don't rewrite them needlessly.
+var lastPostsHash string
+
+func postsHash(posts []Post) string {
+	data, _ := json.Marshal(posts)
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+// latestDate is the most recent publish date, or update date when by is
+// "updated". It is used instead of time.Now() so that feed and sitemap are
+// stable between builds.
+func latestDate(posts []Post, by string) time.Time {
+	var latest time.Time
+	for _, p := range posts {
+		d := p.Date
+		if by == "updated" {
+			d = p.Updated
+		}
+		if d.After(latest) {
+			latest = d
+		}
+	}
+	return latest
+}
+
+// allExist reports whether all paths exist, false for none.
+func allExist(paths []string) bool {
+	for _, p := range paths {
+		if !fileExists(p) {
+			return false
+		}
+	}
+	return len(paths) > 0
+}
+
+// modTime returns the modification time of path, zero if it doesn't exist.
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// inputDir holds the markdown posts, outputDir receives the generated site.
+var (
+	inputDir  = "articles"
+	outputDir = "public"
+)
+
+// showSchedule prints the scheduled posts after each build.
+var showSchedule bool
+
+// includeFuture publishes posts dated after today right away.
+var includeFuture bool
+
+// minify shrinks the generated HTML, see minifyHTML.
+var minify bool
+
+// includeDrafts builds posts marked "draft: true" like any other post.
+var includeDrafts bool
+
+// withholdDrafts splits drafts off posts and removes pages an earlier build
+// generated for them, so a post turned back into a draft disappears.
+func withholdDrafts(posts []Post) (published []Post, drafts int) {
+	for _, p := range posts {
+		if !p.Draft {
+			published = append(published, p)
+			continue
+		}
+		drafts++
+		for _, page := range []string{postFile(p), filepath.Join(outputDir, filepath.FromSlash(ampURL(p)))} {
+			if err := os.Remove(page); err == nil {
+				buildLog.Printf("", "removed: %s", page)
+			}
+		}
+	}
+	return published, drafts
+}
+
+// buildSite builds the whole site. A failing page or file doesn't stop the
+// build, all failures are returned together once everything else is written.
+func buildSite() error {
+	var errs []error
+	var fail func(error)
+	fail = func(err error) {
+		// count the single failures of a joined error
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, err := range joined.Unwrap() {
+				fail(err)
+			}
+		} else if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	posts, scheduled, err := loadPosts(inputDir)
+	fail(err)
+	if showSchedule {
+		reportSchedule(scheduled)
+	} else if len(scheduled) > 0 {
+		for _, p := range scheduled {
+			buildLog.Printf("", "skipped: %s (dated %s)", p.Source, p.Date.Format("2006-01-02"))
+		}
+		buildLog.Printf("", "Withheld %d future-dated post(s), use -schedule to list them or -future to publish them", len(scheduled))
+	}
+	if !includeDrafts {
+		var drafts int
+		if posts, drafts = withholdDrafts(posts); drafts > 0 {
+			buildLog.Printf("", "Withheld %d draft(s), use -drafts to include them", drafts)
+		}
+	}
+	postIndex = map[string]*Post{}
+	for i := range posts {
+		postIndex[posts[i].Slug] = &posts[i]
+	}
+	resetManifest()
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+	fail(copyStaticAssets())
+	fail(generateIndex(posts))
+	fail(generatePosts(posts))
+	if config.AMP {
+		fail(generateAMP(posts))
+	}
+	fail(generateUpdates(posts))
+	fail(generateAll(posts))
+	fail(generateTags(posts))
+	fail(generateNotFound(posts))
+	// feed and sitemap only depend on the posts, skip them when nothing changed
+	feed, rss := filepath.Join(outputDir, "feed.xml"), filepath.Join(outputDir, "rss.xml")
+	if hash := postsHash(posts); hash != lastPostsHash || !fileExists(feed) || !fileExists(rss) || !allExist(sitemapFiles) {
+		var err error
+		sitemapFiles, err = generateSitemap(posts)
+		err = errors.Join(err, generateFeed(posts), generateRSS(posts))
+		fail(err)
+		// write them again next time unless everything succeeded
+		if err == nil {
+			lastPostsHash = hash
+		}
+	} else {
+		recordExisting(append(sitemapFiles, feed, rss)...)
+	}
+	fail(generateHumansTxt())
+	fail(generateSecurityTxt())
+	fail(writeManifest())
+	fail(checkInternalLinks(posts))
+	if checkExternal {
+		checkExternalLinks(posts)
+	}
+	if afterBuild != nil {
+		defer afterBuild()
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d error(s):\n%w", len(errs), errors.Join(errs...))
+	}
+	buildLog.Printf("", "Build complete.")
+	return nil
+}
+
+// afterBuild, if set, runs after every completed build.
+var afterBuild func()
+
+// buildFunc is the build rebuild runs, replaced in tests.
+var buildFunc = buildSite
+
+// builds keeps rebuilds from overlapping on the output directory.
+var builds struct {
+	sync.Mutex
+	running bool
+	pending bool // another rebuild was requested while running
+}
+
+// rebuild runs buildSite, or, if a build is already running, queues a single
+// rebuild after it. Requests arriving while one is queued are folded into it.
+func rebuild() {
+	builds.Lock()
+	if builds.running {
+		builds.pending = true
+		builds.Unlock()
+		return
+	}
+	builds.running = true
+	builds.Unlock()
+	for {
+		start := time.Now()
+		if err := buildFunc(); err != nil {
+			buildLog.Warnf("", "build failed with %v", err)
+		}
+		buildLog.Printf("", "rebuilt in %dms", time.Since(start).Milliseconds())
+		builds.Lock()
+		if !builds.pending {
+			builds.running = false
+			builds.Unlock()
+			return
+		}
+		builds.pending = false
+		builds.Unlock()
+	}
 }
 
 func main() {
 	watch := flag.Bool("watch", false, "Rebuild site on file changes")
+	flag.BoolVar(&showSchedule, "schedule", false, "List future-dated posts that are withheld from the build")
+	flag.BoolVar(&strict, "strict", false, "Fail the build on broken internal links instead of warning")
+	flag.BoolVar(&checkExternal, "check-links", false, "Check external links in posts (results are cached in "+linkCacheFile+")")
+	clean := flag.Bool("clean", false, "Remove the previous build's output before building")
+	flag.BoolVar(&includeDrafts, "drafts", false, "Include posts marked as draft")
+	flag.BoolVar(&includeFuture, "future", false, "Include posts dated after today")
+	archive := flag.String("archive", "", "Also pack the built site into this .tar.gz or .zip file")
+	flag.BoolVar(&minify, "minify", false, "Minify the generated HTML")
+	flag.StringVar(&inputDir, "in", inputDir, "Directory to read posts from")
+	flag.StringVar(&outputDir, "out", outputDir, "Directory to write the site to")
 	flag.Parse()
+	if err := loadConfigFile(configFile, &config); err != nil {
+		log.Fatal(err)
+	}
+	expandConfigEnv(&config)
 	args := flag.Args()
 	if len(args) > 0 {
 		switch args[0] {
 		case "image":
 			runImageCommand(args[1:])
 			return
+		case "init":
+			runInitCommand(args[1:])
+			return
+		case "serve":
+			runServeCommand(args[1:])
+			return
+		case "clean":
+			runCleanCommand(args[1:])
+			return
 		case "build":
-			args = args[1:]
+			// build takes the same flags before or after the subcommand
+			flag.CommandLine.Parse(args[1:])
+			args = flag.Args()
 		default:
 			log.Fatalf("unknown command %q", args[0])
 		}
 	}
-	buildSite()
-	fmt.Printf("Built site to: %s/index.html\n", filepath.Join(os.Getenv("PWD"), "public"))
+	if *clean {
+		if err := cleanOutput(outputDir); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := buildSite(); err != nil {
+		log.Fatalf("build failed with %v", err)
+	}
+	fmt.Printf("Built site to: %s/index.html\n", filepath.Join(os.Getenv("PWD"), outputDir))
+	if *archive != "" {
+		if err := writeArchive(outputDir, *archive); err != nil {
+			log.Fatal(err)
+		}
+	}
 	if *watch {
 		fmt.Println("Watching for changes...")
 		watchFiles()
 	}
 }
 
-func loadPosts(dir string) []Post {
-	files, _ := os.ReadDir(dir)
-	var posts []Post
+// loadPosts reads all posts from dir, newest first. Posts dated after today
+// are withheld and returned separately as scheduled, unless includeFuture is
+// set. Posts that can't be read are left out and reported in err.
+func loadPosts(dir string) (posts []Post, scheduled []Post, err error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	var all []Post
+	var errs []error
 	for _, f := range files {
 		if strings.HasSuffix(f.Name(), ".md") {
 			path := filepath.Join(dir, f.Name())
 
-			if len(f.Name()) < 10 {
-				log.Printf("Warning: skipping %s - filename too short, expected format: YYYY-MM-DD-title.md", f.Name())
+			data, err := os.ReadFile(path)
+			if err != nil {
+				errs = append(errs, err)
 				continue
 			}
+			// normalize Windows and old Mac line endings before any line based parsing
+			text := strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(string(data))
+			meta, body := parseFrontMatter(text)
 
-			dateStr := f.Name()[:10]
-			postDate, err := time.Parse("2006-01-02", dateStr)
-			if err != nil {
-				log.Printf("Warning: skipping %s - invalid date format in filename prefix, expected YYYY-MM-DD, got: %s", f.Name(), dateStr)
+			// a front matter date overrides the filename prefix
+			var postDate time.Time
+			if v, ok := meta["date"]; ok {
+				if postDate, err = time.Parse("2006-01-02", v); err != nil {
+					buildLog.Warnf(f.Name(), "skipping - invalid front matter date %q, expected YYYY-MM-DD", v)
+					continue
+				}
+			} else if len(f.Name()) < 10 {
+				buildLog.Warnf(f.Name(), "skipping - filename too short, expected format: YYYY-MM-DD-title.md")
+				continue
+			} else if postDate, err = time.Parse("2006-01-02", f.Name()[:10]); err != nil {
+				buildLog.Warnf(f.Name(), "skipping - invalid date format in filename prefix, expected YYYY-MM-DD, got: %s", f.Name()[:10])
 				continue
 			}
 
-			data, _ := os.ReadFile(path)
-			content, title, excerpt := parseMarkdown(string(data))
+			content, title, excerpt, headings := parseMarkdown(body, f.Name())
+			if v := meta["title"]; v != "" {
+				if title == "" && !config.StripTitle {
+					content = "<h1>" + formatInline(v) + "</h1>\n" + content
+				}
+				title = plainText(formatInline(v))
+			}
+			if config.StripTitle && title != "" {
+				if rest, ok := strings.CutPrefix(content, "<h1>"); ok {
+					_, content, _ = strings.Cut(rest, "</h1>\n")
+				}
+			}
+			draft := false
+			if v, ok := meta["draft"]; ok {
+				if draft, err = strconv.ParseBool(v); err != nil {
+					buildLog.Warnf(f.Name(), "invalid draft value %q, expected true or false", v)
+				}
+			}
 			slug := strings.TrimSuffix(f.Name(), ".md")
-			posts = append(posts, Post{
-				Title:   title,
-				Slug:    slug,
-				Date:    postDate,
-				Content: template.HTML(content),
-				Excerpt: excerpt,
-			})
+			words, codeWords := countWords(body)
+			updated := postDate
+			if v, ok := meta["updated"]; ok {
+				if updated, err = time.Parse("2006-01-02", v); err != nil {
+					buildLog.Warnf(f.Name(), "invalid updated date %q, expected YYYY-MM-DD", v)
+					updated = postDate
+				}
+			}
+			cover := ""
+			if v, ok := meta["cover"]; ok {
+				cover = absoluteURL(v, "/"+permalink(slug, postDate))
+			}
+			kind := meta["kind"]
+			switch kind {
+			case "article", "note", "link":
+			case "":
+				kind = "article"
+			default:
+				buildLog.Warnf(f.Name(), "unknown kind %q, expected article, note or link", kind)
+				kind = "article"
+			}
+			if kind == "link" && meta["link_url"] == "" {
+				buildLog.Warnf(f.Name(), "link post without link_url")
+			}
+			section := kind
+			if v := sanitizeAnchor(meta["section"]); v != "" {
+				section = v
+			}
+			changeFreq, priority := postChangeFreq, postPriority
+			if v, ok := meta["changefreq"]; ok {
+				if changeFreqs[v] {
+					changeFreq = v
+				} else {
+					buildLog.Warnf(f.Name(), "invalid changefreq %q, expected always, hourly, daily, weekly, monthly, yearly or never", v)
+				}
+			}
+			if v, ok := meta["priority"]; ok {
+				if p, err := strconv.ParseFloat(v, 64); err == nil && p >= 0 && p <= 1 {
+					priority = strconv.FormatFloat(p, 'f', 1, 64)
+				} else {
+					buildLog.Warnf(f.Name(), "invalid priority %q, expected 0.0 to 1.0", v)
+				}
+			}
+			layoutClass := config.ArticleClass
+			if v := meta["layout"]; v != "" {
+				layoutClass = strings.TrimSpace(layoutClass + " " + sanitizeAnchor(v))
+			}
+			author := meta["author"]
+			if author == "" {
+				author = config.Author
+			}
+			post := Post{
+				Title:       title,
+				Slug:        slug,
+				Source:      f.Name(),
+				URL:         permalink(slug, postDate),
+				Date:        postDate,
+				Updated:     updated,
+				Cover:       cover,
+				Author:      author,
+				Kind:        kind,
+				LinkURL:     meta["link_url"],
+				LayoutClass: layoutClass,
+				Section:     section,
+				ChangeFreq:  changeFreq,
+				Priority:    priority,
+				Tags:        splitList(meta["tags"]),
+				Draft:       draft,
+				Content:     template.HTML(content),
+				WordCount:   words,
+				ReadingTime: readingTime(words, codeWords),
+				Headings:    headings,
+				TOC:         tocHeadings(headings, config.TOCMaxDepth),
+			}
+			buildExcerpt(&post, excerpt, meta, config.ExcerptMode)
+			all = append(all, post)
 		}
 	}
 
-	sort.Slice(posts, func(i, j int) bool {
-		return posts[i].Date.After(posts[j].Date)
+	// newest first; posts of the same day by filename, so their order and
+	// anything derived from it is the same on every build
+	sort.Slice(all, func(i, j int) bool {
+		if !all[i].Date.Equal(all[j].Date) {
+			return all[i].Date.After(all[j].Date)
+		}
+		if all[i].Source != all[j].Source {
+			return all[i].Source < all[j].Source
+		}
+		return all[i].Slug < all[j].Slug
 	})
+	relocateLinks(all)
 
-	return posts
+	// compare dates only, a post dated today is always published
+	y, m, d := time.Now().Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	for _, p := range all {
+		if p.Date.After(today) && !includeFuture {
+			scheduled = append(scheduled, p)
+		} else {
+			posts = append(posts, p)
+		}
+	}
+	return posts, scheduled, errors.Join(errs...)
+}
+
+// reportSchedule lists the withheld future posts, the next one first.
+func reportSchedule(scheduled []Post) {
+	if len(scheduled) == 0 {
+		buildLog.Printf("", "No scheduled posts.")
+		return
+	}
+	buildLog.Printf("", "Scheduled posts:")
+	for i := len(scheduled) - 1; i >= 0; i-- {
+		buildLog.Printf("", "  %s  %s (%s)", scheduled[i].Date.Format("2006-01-02"), scheduled[i].Title, scheduled[i].Slug)
+	}
+}
+
+// absoluteURL resolves ref against the page at pagePath under config.BaseURL,
+// so "../images/x.png" in an article ends up as BaseURL/images/x.png.
+func absoluteURL(ref, pagePath string) string {
+	base, err := url.Parse(config.BaseURL + pagePath)
+	if err != nil {
+		return ref
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return base.ResolveReference(u).String()
+}
+
+// isRelativeURL reports whether ref is a path relative to the current page,
+// as opposed to absolute URLs, root paths, fragments and mailto: and friends.
+func isRelativeURL(ref string) bool {
+	u, err := url.Parse(ref)
+	return err == nil && u.Scheme == "" && u.Host == "" && !strings.HasPrefix(ref, "/") && !strings.HasPrefix(ref, "#")
+}
+
+// rewriteLinks replaces every href and src attribute value in content with
+// fn applied to the unescaped value, and every URL of a srcset.
+func rewriteLinks(content string, fn func(string) string) string {
+	return linkAttrRe.ReplaceAllStringFunc(content, func(m string) string {
+		attr, value, _ := strings.Cut(m, "=")
+		ref := html.UnescapeString(strings.Trim(value, `"`))
+		if attr == "srcset" {
+			candidates := strings.Split(ref, ",")
+			for i, c := range candidates {
+				if fields := strings.Fields(c); len(fields) > 0 {
+					fields[0] = fn(fields[0])
+					candidates[i] = strings.Join(fields, " ")
+				}
+			}
+			return attr + `="` + html.EscapeString(strings.Join(candidates, ", ")) + `"`
+		}
+		return attr + `="` + html.EscapeString(fn(ref)) + `"`
+	})
+}
+
+// sortPosts returns a copy of posts ordered newest first by the given key:
+// "updated" uses the last update, anything else the publish date.
+func sortPosts(posts []Post, by string) []Post {
+	sorted := append([]Post(nil), posts...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if by == "updated" {
+			return sorted[i].Updated.After(sorted[j].Updated)
+		}
+		return sorted[i].Date.After(sorted[j].Date)
+	})
+	return sorted
+}
+
+var (
+	tagRe          = regexp.MustCompile(`<[^>]*>`)
+	sentenceEndRe  = regexp.MustCompile(`[.!?]+["')\]]*\s+`)
+	abbreviationRe = regexp.MustCompile(`(?i)(\b(e\.g|i\.e|etc|vs|cf|mr|mrs|ms|dr|st)|\b\p{L})\.$`)
+)
+
+// plainText strips tags from rendered HTML and unescapes entities.
+func plainText(s string) string {
+	return html.UnescapeString(tagRe.ReplaceAllString(s, ""))
+}
+
+// buildExcerpt sets the teaser of post, Excerpt as plain text and ExcerptHTML
+// for listings. The captured excerpt from parseMarkdown is shortened by mode;
+// front matter "summary" (plain text) and "excerpt" (markdown) take precedence,
+// and config.ExcerptFallback fills in when nothing is left. Footnote references
+// are dropped. Both variants are trimmed with whitespace runs collapsed, and
+// the HTML has balanced tags.
+func buildExcerpt(post *Post, captured string, meta map[string]string, mode string) {
+	// footnote references would link to notes that aren't part of the teaser
+	captured = footnoteRefHTMLRe.ReplaceAllString(captured, "")
+	rendered := applyExcerptMode(captured, mode)
+	if v, ok := meta["summary"]; ok {
+		rendered = html.EscapeString(v)
+	}
+	if v, ok := meta["excerpt"]; ok {
+		rendered = formatInline(v)
+	}
+	rendered = balanceHTML(collapseSpace(rendered))
+	plain := collapseSpace(plainText(rendered))
+	if v, ok := meta["summary"]; ok {
+		plain = collapseSpace(v)
+	}
+	if plain == "" && config.ExcerptFallback != "" {
+		plain = config.ExcerptFallback
+		if plain == "title" {
+			plain = post.Title
+		}
+		rendered = html.EscapeString(plain)
+	}
+	post.Excerpt = plain
+	post.ExcerptHTML = template.HTML(rendered)
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+var (
+	htmlTagRe    = regexp.MustCompile(`<(/?)([a-zA-Z][a-zA-Z0-9-]*)[^>]*?(/?)>`)
+	voidElements = map[string]bool{"area": true, "br": true, "col": true, "embed": true, "hr": true, "img": true, "input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true}
+)
+
+// balanceHTML drops closing tags without an opening one and closes elements
+// still open at the end, so a fragment can't break the page it is put in.
+func balanceHTML(s string) string {
+	var out strings.Builder
+	var open []string
+	last := 0
+	for _, m := range htmlTagRe.FindAllStringSubmatchIndex(s, -1) {
+		out.WriteString(s[last:m[0]])
+		last = m[1]
+		tag := strings.ToLower(s[m[4]:m[5]])
+		if m[3] > m[2] { // closing tag
+			i := len(open) - 1
+			for i >= 0 && open[i] != tag {
+				i--
+			}
+			if i < 0 {
+				continue
+			}
+			for j := len(open) - 1; j > i; j-- {
+				out.WriteString("</" + open[j] + ">")
+			}
+			open = open[:i]
+		} else if m[7] == m[6] && !voidElements[tag] {
+			open = append(open, tag)
+		}
+		out.WriteString(s[m[0]:m[1]])
+	}
+	out.WriteString(s[last:])
+	for i := len(open) - 1; i >= 0; i-- {
+		out.WriteString("</" + open[i] + ">")
+	}
+	return out.String()
+}
+
+// applyExcerptMode shortens the captured first paragraph according to mode.
+// "sentences:N" keeps the first N sentences as escaped plain text; any other
+// mode keeps the paragraph as is.
+func applyExcerptMode(excerpt, mode string) string {
+	n, err := strconv.Atoi(strings.TrimPrefix(mode, "sentences:"))
+	if !strings.HasPrefix(mode, "sentences:") || err != nil || n < 1 {
+		return excerpt
+	}
+	return html.EscapeString(firstSentences(plainText(excerpt), n))
+}
+
+// firstSentences splits on ., ! or ? followed by whitespace, skipping common
+// abbreviations and initials like "e.g." or "J.".
+func firstSentences(text string, n int) string {
+	for _, m := range sentenceEndRe.FindAllStringIndex(text, -1) {
+		if abbreviationRe.MatchString(strings.TrimRight(text[:m[1]], " \t\n")) {
+			continue
+		}
+		if n--; n == 0 {
+			return strings.TrimSpace(text[:m[1]])
+		}
+	}
+	return strings.TrimSpace(text)
+}
+
+const wordsPerMinute = 200
+
+// fenceLine reports whether line opens or closes a ``` or ~~~ code block and
+// updates fence, the marker of the open block or "" outside of code. A block
+// only closes on the marker it was opened with.
+func fenceLine(fence *string, line string) bool {
+	for _, marker := range []string{"```", "~~~"} {
+		if !strings.HasPrefix(line, marker) {
+			continue
+		}
+		switch *fence {
+		case "":
+			*fence = marker
+			return true
+		case marker:
+			*fence = ""
+			return true
+		}
+	}
+	return false
+}
+
+// countWords splits the markdown source into prose and fenced code words,
+// tracking fences the same way parseMarkdown does.
+func countWords(input string) (prose int, code int) {
+	fence := ""
+	for _, raw := range strings.Split(input, "\n") {
+		if fenceLine(&fence, strings.TrimSpace(raw)) {
+			continue
+		}
+		if fence != "" {
+			code += len(strings.Fields(raw))
+		} else {
+			prose += proseWords(raw)
+		}
+	}
+	return prose, code
+}
+
+var footnoteLabelRe = regexp.MustCompile(`\[\^[^\]\s]+\]:?`)
+
+// proseWords counts the words of a markdown line as they are read: links and
+// images by their text rather than their URL, and no tokens without a letter
+// or digit, like list bullets or heading, emphasis and table markup.
+func proseWords(line string) int {
+	line = strings.TrimSpace(line)
+	if linkRefDefRe.MatchString(line) {
+		return 0
+	}
+	line = orderedItemRe.ReplaceAllString(strings.ReplaceAll(line, moreMarker, ""), "")
+	line = footnoteLabelRe.ReplaceAllString(line, "")
+	line = imageRe.ReplaceAllString(line, "$1")
+	line = linkRe.ReplaceAllString(line, "$1")
+	line = linkRefUseRe.ReplaceAllString(line, "$2")
+	words := 0
+	for _, field := range strings.Fields(line) {
+		if strings.IndexFunc(field, isWordRune) >= 0 {
+			words++
+		}
+	}
+	return words
+}
+
+// readingTime reads prose at wordsPerMinute and code at the slower
+// config.CodeWordsPerMinute. It rounds up so that even short posts read as one minute.
+func readingTime(prose, code int) int {
+	minutes := float64(prose) / wordsPerMinute
+	if config.CodeWordsPerMinute > 0 {
+		minutes += float64(code) / float64(config.CodeWordsPerMinute)
+	}
+	return int(math.Ceil(minutes))
 }
 
 var (
-	codeRe   = regexp.MustCompile("`([^`\n]+)`")
-	boldRe   = regexp.MustCompile(`\*\*(.+?)\*\*`)
-	italicRe = regexp.MustCompile(`\*(.+?)\*`)
-	strikeRe = regexp.MustCompile(`~~(.+?)~~`)
-	imageRe  = regexp.MustCompile(`!\[([^\]]*)\]\(([^)]+)\)`)
-	linkRe   = regexp.MustCompile(`\[([^\]]*)\]\(([^)]+)\)`)
+	// ``double backticks`` allow a literal ` inside, one padding space each side is dropped
+	codeRe = regexp.MustCompile("``[ ]?(.+?)[ ]?``|`([^`\n]+)`")
+	spanRe = regexp.MustCompile("\x00([0-9]+)\x00")
+	// URLs written out as text, up to the next space or placeholder
+	bareURLRe = regexp.MustCompile("\\b(?:https?|ftp)://[^\\s\x00]+")
+	// emphasis delimiters need text right inside them, so a * b * c stays math
+	strongEmRe = regexp.MustCompile(`\*\*\*(\S(?:.*?\S)??)\*\*\*`)
+	boldRe     = regexp.MustCompile(`\*\*(\S(?:.*?\S)??)\*\*`)
+	markRe     = regexp.MustCompile(`==(\S(?:.*?\S)??)==`)
+	subRe      = regexp.MustCompile(`~([^~\s/]+)~`) // no slashes, ~/paths~ aren't chemistry
+	supRe      = regexp.MustCompile(`\^([^^\s/]+)\^`)
+	italicRe   = regexp.MustCompile(`\*(\S(?:.*?\S)??)\*`)
+	strikeRe   = regexp.MustCompile(`~~(.+?)~~`)
+	// underscores only count at word boundaries, snake_case_words stay as is
+	underBoldRe   = regexp.MustCompile(`\b__(\S(?:.*?\S)??)__\b`)
+	underItalicRe = regexp.MustCompile(`\b_(\S(?:.*?\S)??)_\b`)
+	imageRe       = regexp.MustCompile(`!\[([^\]]*)\]\(` + linkTargetPattern + `\)`)
+	linkRe        = regexp.MustCompile(`\[([^\]]*)\]\(` + linkTargetPattern + `\)`)
 )
 
+// linkTargetPattern matches the (url "title") part of links and images: a URL
+// without spaces that may contain balanced parentheses, like Wikipedia's, and
+// an optional title in single or double quotes, escaped or not. Targets that
+// don't fit stay literal text.
+const linkTargetPattern = `((?:[^()\s]|\([^()\s]*\))+)(?:\s+(?:"|&#34;|'|&#39;)(.*?)(?:"|&#34;|'|&#39;))?\s*`
+
+// titleAttr renders an optional, already escaped, title attribute.
+func titleAttr(title string) string {
+	if title == "" {
+		return ""
+	}
+	return ` title="` + title + `"`
+}
+
+var mediaElements = map[string]string{
+	".mp3": "audio", ".ogg": "audio", ".wav": "audio", ".m4a": "audio", ".flac": "audio", ".opus": "audio",
+	".mp4": "video", ".webm": "video", ".mov": "video", ".ogv": "video",
+}
+
+// renderImage turns an already escaped ![alt](src) match into a figure,
+// using <audio>/<video> for media files and <img> for everything else, with a
+// srcset when `image -srcset` variants of the image exist.
+func renderImage(match string) string {
+	m := imageRe.FindStringSubmatch(match)
+	alt, src, title := m[1], m[2], titleAttr(m[3])
+	if el, ok := mediaElements[strings.ToLower(path.Ext(src))]; ok {
+		return fmt.Sprintf(`<figure><%s controls src="%s"%s>%s</%s><figcaption>%s</figcaption></figure>`, el, src, title, alt, el, alt)
+	}
+	return fmt.Sprintf(`<figure><img src="%s" alt="%s"%s%s><figcaption>%s</figcaption></figure>`, src, alt, title, imageSrcset(src), alt)
+}
+
 func formatInline(text string) string {
 	text = html.EscapeString(text)
-	text = imageRe.ReplaceAllString(text, `<figure><img src="$2" alt="$1"><figcaption>$1</figcaption></figure>`)
-	text = linkRe.ReplaceAllString(text, `<a href="$2">$1</a>`)
-	text = codeRe.ReplaceAllString(text, "<code>$1</code>")
+	// swap code spans and the markup of links and images for placeholders, so
+	// no other formatting applies inside code or to URLs and alt texts
+	var spans []string
+	protect := func(s string) string {
+		spans = append(spans, s)
+		return fmt.Sprintf("\x00%d\x00", len(spans)-1)
+	}
+	codeTag := "<code>"
+	if config.InlineCodeClass != "" {
+		codeTag = "<code class=\"" + html.EscapeString(config.InlineCodeClass) + "\">"
+	}
+	text = codeRe.ReplaceAllStringFunc(text, func(m string) string {
+		sub := codeRe.FindStringSubmatch(m)
+		return protect(codeTag + sub[1] + sub[2] + "</code>")
+	})
+	text = imageRe.ReplaceAllStringFunc(text, func(m string) string {
+		return protect(renderImage(m))
+	})
+	// the link text stays in place to be formatted like the rest
+	text = linkRe.ReplaceAllStringFunc(text, func(match string) string {
+		m := linkRe.FindStringSubmatch(match)
+		return protect(`<a href="`+m[2]+`"`+titleAttr(m[3])+`>`) + m[1] + "</a>"
+	})
+	// URLs in the text are no place for emphasis either
+	text = bareURLRe.ReplaceAllStringFunc(text, protect)
+	// bold always before italic, so ** is never taken for two single stars
+	text = strongEmRe.ReplaceAllString(text, "<strong><em>$1</em></strong>")
 	text = boldRe.ReplaceAllString(text, "<strong>$1</strong>")
+	text = underBoldRe.ReplaceAllString(text, "<strong>$1</strong>")
+	text = replaceBetweenWords(text, markRe, "<mark>$1</mark>")
 	text = strikeRe.ReplaceAllString(text, "<del>$1</del>")
+	// single tildes are left over once ~~strike~~ is resolved
+	text = subRe.ReplaceAllString(text, "<sub>$1</sub>")
+	text = supRe.ReplaceAllString(text, "<sup>$1</sup>")
 	text = italicRe.ReplaceAllString(text, "<em>$1</em>")
-	return text
+	text = underItalicRe.ReplaceAllString(text, "<em>$1</em>")
+	return restoreSpans(text, spans)
+}
+
+// restoreSpans puts the protected spans back in place of their placeholders,
+// including placeholders within spans, like a code span in an image's alt.
+func restoreSpans(text string, spans []string) string {
+	return spanRe.ReplaceAllStringFunc(text, func(m string) string {
+		i, _ := strconv.Atoi(spanRe.FindStringSubmatch(m)[1])
+		if i >= len(spans) {
+			return m
+		}
+		// spans only contain placeholders of the spans before them
+		return restoreSpans(spans[i], spans[:i])
+	})
+}
+
+// replaceBetweenWords replaces the matches of re like ReplaceAllString, but
+// leaves matches touching a letter or digit on either side alone, so that
+// operators like x==y==z stay as they are.
+func replaceBetweenWords(text string, re *regexp.Regexp, repl string) string {
+	var out []byte
+	last := 0
+	for _, m := range re.FindAllStringSubmatchIndex(text, -1) {
+		before, _ := utf8.DecodeLastRuneInString(text[:m[0]])
+		after, _ := utf8.DecodeRuneInString(text[m[1]:])
+		if isWordRune(before) || isWordRune(after) {
+			continue
+		}
+		out = append(out, text[last:m[0]]...)
+		out = re.ExpandString(out, repl, text, m)
+		last = m[1]
+	}
+	return string(append(out, text[last:]...))
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
 }
 
-func parseMarkdown(input string) (content string, title string, excerpt string) {
+// indentWidth measures leading whitespace, counting a tab as four spaces.
+func indentWidth(raw string) int {
+	width := 0
+	for _, r := range raw {
+		switch r {
+		case ' ':
+			width++
+		case '\t':
+			width += 4
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+var orderedItemRe = regexp.MustCompile(`^(\d+)\.\s+`)
+
+// subHeadingRe matches ### to ###### headings, which get an id but no self-link.
+var subHeadingRe = regexp.MustCompile(`^(#{3,6}) (.*)$`)
+
+// headingLikeRe matches lines that are headings or rules rather than prose,
+// including deeper levels and underlines that end up in the default case.
+var headingLikeRe = regexp.MustCompile(`^(#{1,6}(\s|$)|=+$|-{3,}$|\*{3,}$)`)
+
+// tocHeadings keeps the headings down to depth levels below the title.
+func tocHeadings(headings []Heading, depth int) []Heading {
+	if depth <= 0 {
+		depth = 2
+	}
+	var toc []Heading
+	for _, h := range headings {
+		if h.Level-1 <= depth {
+			toc = append(toc, h)
+		}
+	}
+	return toc
+}
+
+// moreMarker ends a hand-picked excerpt, see parseMarkdown.
+const moreMarker = "<!--more-->"
+
+// hardBreak reports whether raw ends in a hard line break, two spaces or a
+// backslash, and returns the line without it.
+func hardBreak(raw string) (string, bool) {
+	line := strings.TrimSpace(raw)
+	if strings.HasSuffix(raw, "  ") {
+		return line, line != ""
+	}
+	if text, ok := strings.CutSuffix(line, `\`); ok {
+		return strings.TrimSpace(text), true
+	}
+	return line, false
+}
+
+var setextUnderlineRe = regexp.MustCompile(`^(=+|-+)$`)
+
+// setextLevel returns 1 or 2 if lines[i] underlines the text above it with
+// === or --- and 0 otherwise.
+func setextLevel(lines []string, i int) int {
+	if i >= len(lines) {
+		return 0
+	}
+	switch line := strings.TrimSpace(lines[i]); {
+	case !setextUnderlineRe.MatchString(line):
+		return 0
+	case line[0] == '=':
+		return 1
+	}
+	return 2
+}
+
+// paragraphContinues reports whether lines[i] is plain text that can follow
+// a paragraph line rather than a blank line or the start of another block.
+func paragraphContinues(lines []string, i int) bool {
+	if i >= len(lines) {
+		return false
+	}
+	line := strings.TrimSpace(lines[i])
+	switch {
+	case line == "", strings.Contains(line, moreMarker), headingLikeRe.MatchString(line), setextUnderlineRe.MatchString(line),
+		strings.HasPrefix(line, "```"), strings.HasPrefix(line, "~~~"), strings.HasPrefix(line, ">"),
+		strings.HasPrefix(line, "- "), strings.HasPrefix(line, "!!! "), orderedItemRe.MatchString(line),
+		isTableStart(lines, i):
+		return false
+	}
+	return true
+}
+
+// isProse reports whether a paragraph line is suitable as an excerpt. Image-only
+// paragraphs render as <figure> and make poor teasers, heading-like lines are
+// not prose at all.
+func isProse(line string) bool {
+	return !headingLikeRe.MatchString(line) && strings.TrimSpace(imageRe.ReplaceAllString(line, "")) != ""
+}
+
+func parseMarkdown(input, source string) (content string, title string, excerpt string, headings []Heading) {
 	lines := strings.Split(input, "\n")
 	var out, exc strings.Builder
-	inList := false
-	inCode := false
+	// open lists, innermost last; the last <li> of each is still open
+	type openList struct {
+		tag    string
+		indent int
+	}
+	var lists []openList
+	fence := "" // marker of the open code block
 	codeLang := ""
 	firstParagraphCaptured := false
+	// with a moreMarker the excerpt is everything rendered before it
+	moreAt, moreAfterLine := -1, false
 
+	// the <h1> renders emphasis, everything else uses the title as plain text
 	if len(lines) > 0 && strings.HasPrefix(lines[0], "# ") {
-		title = strings.TrimPrefix(lines[0], "# ")
+		title = plainText(formatInline(strings.TrimPrefix(lines[0], "# ")))
+	} else if setextLevel(lines, 1) == 1 && strings.TrimSpace(lines[0]) != "" {
+		title = plainText(formatInline(strings.TrimSpace(lines[0])))
+	}
+	lines, refs := collectLinkRefs(lines, source)
+	lines, notes := collectFootnotes(lines)
+	inline := func(text string) string {
+		return notes.resolve(formatInline(notes.link(refs.link(text))))
+	}
+	// heading records an anchored heading and returns its id, suffixed with
+	// -2, -3, ... when an earlier heading already uses it
+	seen := map[string]int{}
+	heading := func(level int, text string) string {
+		id := sanitizeAnchor(text)
+		if seen[id]++; seen[id] > 1 {
+			id += "-" + strconv.Itoa(seen[id])
+		}
+		headings = append(headings, Heading{Level: level, ID: id, Text: plainText(formatInline(text))})
+		return id
+	}
+	closeInnermost := func() {
+		out.WriteString("</li>\n</" + lists[len(lists)-1].tag + ">\n")
+		lists = lists[:len(lists)-1]
+	}
+	closeList := func() {
+		for len(lists) > 0 {
+			closeInnermost()
+		}
+	}
+	// listItem starts an item at the given indentation, nesting a new list in
+	// the open item when indented deeper and closing lists on dedent.
+	listItem := func(tag, attrs string, indent int, text string) {
+		for len(lists) > 0 && lists[len(lists)-1].indent > indent {
+			closeInnermost()
+		}
+		if n := len(lists); n > 0 && lists[n-1].indent == indent {
+			if lists[n-1].tag == tag {
+				out.WriteString("</li>\n")
+			} else {
+				closeInnermost()
+			}
+		}
+		if n := len(lists); n == 0 || lists[n-1].indent < indent {
+			if n > 0 {
+				out.WriteString("\n")
+			}
+			out.WriteString("<" + tag + attrs + ">\n")
+			lists = append(lists, openList{tag, indent})
+		}
+		out.WriteString("<li>" + inline(text))
 	}
 
-	for _, raw := range lines {
+	for i := 0; i < len(lines); i++ {
+		if moreAfterLine {
+			closeList()
+			moreAt, moreAfterLine = out.Len(), false
+		}
+		raw := lines[i]
 		line := strings.TrimSpace(raw)
 
-		if strings.HasPrefix(line, "```") {
-			if inCode {
+		if fenceLine(&fence, line) {
+			if fence == "" {
 				out.WriteString("</code></pre>\n</div>\n")
-				inCode = false
 				continue
 			}
-			inCode = true
-			codeLang = strings.TrimSpace(strings.TrimPrefix(line, "```"))
+			codeLang = strings.TrimSpace(strings.TrimPrefix(line, fence))
 			out.WriteString("<div class=\"code-block-wrapper\">\n<button class=\"copy-button\" onclick=\"copyCode(this)\" aria-label=\"Copy code\">Copy</button>\n")
 			if codeLang == "" {
 				out.WriteString("<pre><code>")
@@ -180,78 +1129,207 @@ func parseMarkdown(input string) (content string, title string, excerpt string)
 			}
 			continue
 		}
-		if inCode {
+		if fence != "" {
 			out.WriteString(html.EscapeString(raw) + "\n")
 			continue
 		}
-		if inList && line == "" {
-			out.WriteString("</ul>\n")
-			inList = false
+		if strings.Contains(line, moreMarker) && moreAt < 0 {
+			raw = strings.Replace(raw, moreMarker, "", 1)
+			line = strings.TrimSpace(raw)
+			if line == "" {
+				closeList()
+				moreAt = out.Len()
+				continue
+			}
+			moreAfterLine = true
+		}
+		if len(lists) > 0 && line == "" {
+			closeList()
 			continue
 		}
 
 		switch {
-		case strings.HasPrefix(line, "> "):
-			if inList {
-				out.WriteString("</ul>\n")
-				inList = false
+		case config.Admonitions && strings.HasPrefix(line, "!!! "):
+			closeList()
+			kind, title, _ := strings.Cut(strings.TrimPrefix(line, "!!! "), " ")
+			var body []string
+			for i+1 < len(lines) && (strings.TrimSpace(lines[i+1]) == "" || strings.HasPrefix(lines[i+1], "    ") || strings.HasPrefix(lines[i+1], "\t")) {
+				i++
+				body = append(body, refs.link(strings.TrimPrefix(strings.TrimPrefix(lines[i], "\t"), "    ")))
 			}
-			out.WriteString("<blockquote><p>" + formatInline(strings.TrimPrefix(line, "> ")) + "</p></blockquote>\n")
-		case strings.HasPrefix(line, "# "):
-			if inList {
-				out.WriteString("</ul>\n")
-				inList = false
+			out.WriteString(renderAdmonition(kind, title, strings.Join(body, "\n"), source))
+		case strings.HasPrefix(line, "> [!") && strings.HasSuffix(line, "]"):
+			closeList()
+			kind := strings.TrimSuffix(strings.TrimPrefix(line, "> [!"), "]")
+			var body []string
+			for i+1 < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i+1]), ">") {
+				i++
+				quoted := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				body = append(body, strings.TrimPrefix(quoted, " "))
 			}
-			out.WriteString("<h1>" + formatInline(strings.TrimPrefix(line, "# ")) + "</h1>\n")
+			if githubAlerts[strings.ToLower(kind)] {
+				for j := range body {
+					body[j] = refs.link(body[j])
+				}
+				out.WriteString(renderAdmonition(kind, "", strings.Join(body, "\n"), source))
+				continue
+			}
+			out.WriteString("<blockquote><p>" + inline(strings.TrimPrefix(line, "> ")) + "</p>")
+			for _, b := range body {
+				if b != "" {
+					out.WriteString("<p>" + inline(b) + "</p>")
+				}
+			}
+			out.WriteString("</blockquote>\n")
+		case strings.HasPrefix(line, "> "):
+			closeList()
+			out.WriteString("<blockquote><p>" + inline(strings.TrimPrefix(line, "> ")) + "</p></blockquote>\n")
+		case strings.HasPrefix(line, "# "):
+			closeList()
+			out.WriteString("<h1>" + inline(strings.TrimPrefix(line, "# ")) + "</h1>\n")
 		case strings.HasPrefix(line, "## "):
-			if inList {
-				out.WriteString("</ul>\n")
-				inList = false
-			}
-			id := sanitizeAnchor(strings.TrimPrefix(line, "## "))
-			out.WriteString("<h2 id=\"" + id + "\"><a href=\"#" + id + "\">" + formatInline(strings.TrimPrefix(line, "## ")) + "</a></h2>\n")
-		case strings.HasPrefix(line, "### "):
-			if inList {
-				out.WriteString("</ul>\n")
-				inList = false
-			}
-			out.WriteString("<h3>" + formatInline(strings.TrimPrefix(line, "### ")) + "</h3>\n")
+			closeList()
+			id := heading(2, strings.TrimPrefix(line, "## "))
+			out.WriteString("<h2 id=\"" + id + "\"><a href=\"#" + id + "\">" + inline(strings.TrimPrefix(line, "## ")) + "</a></h2>\n")
+		case subHeadingRe.MatchString(line):
+			closeList()
+			m := subHeadingRe.FindStringSubmatch(line)
+			level := strconv.Itoa(len(m[1]))
+			id := heading(len(m[1]), m[2])
+			out.WriteString("<h" + level + " id=\"" + id + "\">" + inline(m[2]) + "</h" + level + ">\n")
 		case strings.HasPrefix(line, "- "):
-			if !inList {
-				out.WriteString("<ul>\n")
-				inList = true
+			listItem("ul", "", indentWidth(raw), strings.TrimPrefix(line, "- "))
+		case orderedItemRe.MatchString(line):
+			m := orderedItemRe.FindStringSubmatch(line)
+			attrs := ""
+			// like other Markdown renderers only the first number counts
+			if start, _ := strconv.Atoi(m[1]); start != 1 {
+				attrs = fmt.Sprintf(" start=\"%d\"", start)
 			}
-			out.WriteString("<li>" + formatInline(strings.TrimPrefix(line, "- ")) + "</li>\n")
-		case line == "":
-			if inList {
-				out.WriteString("</ul>\n")
-				inList = false
+			listItem("ol", attrs, indentWidth(raw), line[len(m[0]):])
+		case isTableStart(lines, i):
+			closeList()
+			header, aligns := line, tableAlignments(lines[i+1])
+			i++
+			var rows []string
+			for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" && strings.Contains(lines[i+1], "|") {
+				i++
+				rows = append(rows, lines[i])
 			}
+			out.WriteString(renderTable(header, aligns, rows, inline))
+		case line == "":
+			closeList()
 		default:
-			if inList {
-				out.WriteString("</ul>\n")
-				inList = false
+			closeList()
+			// consecutive text lines form one paragraph, a line ending in two
+			// spaces or a backslash breaks the line within it
+			var para strings.Builder
+			md := line
+			for paragraphContinues(lines, i+1) {
+				if text, brk := hardBreak(raw); brk {
+					para.WriteString(inline(text) + "<br>\n")
+				} else {
+					para.WriteString(inline(line) + "\n")
+				}
+				i++
+				raw = lines[i]
+				line = strings.TrimSpace(raw)
+				md += " " + line
 			}
-			paragraph := formatInline(line)
+			// a === or --- line right below the text makes it a heading
+			switch setextLevel(lines, i+1) {
+			case 1:
+				i++
+				out.WriteString("<h1>" + inline(md) + "</h1>\n")
+				continue
+			case 2:
+				i++
+				id := heading(2, md)
+				out.WriteString("<h2 id=\"" + id + "\"><a href=\"#" + id + "\">" + inline(md) + "</a></h2>\n")
+				continue
+			}
+			para.WriteString(inline(line))
+			paragraph := para.String()
 			out.WriteString("<p>" + paragraph + "</p>\n")
-			if !firstParagraphCaptured {
+			if !firstParagraphCaptured && isProse(md) {
 				exc.WriteString(paragraph)
 				firstParagraphCaptured = true
 			}
 		}
 	}
-	if inList {
-		out.WriteString("</ul>\n")
-	}
-	if inCode {
+	closeList()
+	if fence != "" {
 		out.WriteString("</code></pre>\n</div>\n")
 	}
-	return out.String(), title, exc.String()
+	content = out.String()
+	excerpt = exc.String()
+	if moreAt < 0 && moreAfterLine {
+		moreAt = len(content)
+	}
+	if moreAt >= 0 {
+		excerpt = content[:moreAt]
+		if title != "" {
+			_, excerpt, _ = strings.Cut(excerpt, "</h1>\n")
+		}
+	}
+	return content + notes.section(), title, excerpt, headings
+}
+
+var textExtensions = map[string]bool{".html": true, ".xml": true, ".json": true, ".txt": true, ".css": true}
+
+// normalizeText converts CRLF and lone CR line endings to LF and ends the
+// content with exactly one newline.
+func normalizeText(content []byte) []byte {
+	content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	content = bytes.ReplaceAll(content, []byte("\r"), []byte("\n"))
+	return append(bytes.TrimRight(content, "\n"), '\n')
+}
+
+// githubAlerts are the "> [!KIND]" blockquote types GitHub renders as callouts.
+var githubAlerts = map[string]bool{"note": true, "tip": true, "important": true, "warning": true, "caution": true}
+
+// renderAdmonition renders a callout box whose class is derived from kind
+// (note, tip, warning, ...). The body is regular markdown.
+func renderAdmonition(kind, title, body, source string) string {
+	kind = strings.ToLower(strings.TrimSpace(kind))
+	title = strings.TrimSpace(title)
+	if title == "" && kind != "" {
+		title = strings.ToUpper(kind[:1]) + kind[1:]
+	}
+	content, _, _, _ := parseMarkdown(body, source)
+	return "<div class=\"admonition " + sanitizeAnchor(kind) + "\">\n<p class=\"admonition-title\">" + formatInline(title) + "</p>\n" + content + "</div>\n"
 }
 
 func writeIfChanged(path string, content []byte) error {
-	fmt.Println("writing:", path)
-	return os.WriteFile(path, content, 0644)
+	status, err := writeOutput(path, content)
+	buildLog.Printf("", "%s: %s", status, path)
+	return err
+}
+
+// writeOutput does the work of writeIfChanged without logging, returning
+// "writing" or "unchanged" for the caller to report.
+func writeOutput(path string, content []byte) (status string, err error) {
+	if config.InlineCSS && filepath.Ext(path) == ".html" {
+		content = inlineCSS(content, siteCSS())
+	}
+	if minify && filepath.Ext(path) == ".html" {
+		content = minifyHTML(content)
+	}
+	if !config.KeepLineEndings && textExtensions[filepath.Ext(path)] {
+		content = normalizeText(content)
+	}
+	return storeOutput(path, content)
+}
+
+// storeOutput writes content to path verbatim unless the file already holds
+// exactly that, and records it in the manifest.
+func storeOutput(path string, content []byte) (status string, err error) {
+	recordOutput(path, content)
+	// leave identical files alone so mtimes stay meaningful for rsync and friends
+	if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, content) {
+		return "unchanged", nil
+	}
+	return "writing", os.WriteFile(path, content, 0644)
 }
 
 var funcMap = template.FuncMap{
@@ -259,105 +1337,616 @@ var funcMap = template.FuncMap{
 	"safeHTML": func(s string) template.HTML {
 		return template.HTML(s)
 	},
+	"post": lookupPost,
 }
 
-func generateIndex(posts []Post) {
-	tpl, err := os.ReadFile("index.html")
+// postIndex holds the posts of the current build by slug, for lookupPost.
+var postIndex map[string]*Post
+
+// lookupPost returns the published post with the given slug, so templates
+// can reference posts directly, e.g. {{with post "2025-07-01-hello-blog"}}.
+// Unknown slugs return nil with a warning.
+func lookupPost(slug string) *Post {
+	p, ok := postIndex[slug]
+	if !ok {
+		buildLog.Warnf("template", "post %q not found", slug)
+	}
+	return p
+}
+
+// splitColumns distributes posts over n columns, always appending to the
+// column with the smallest estimated height so columns end up balanced.
+// Order is preserved within each column.
+func splitColumns(posts []Post, n int) [][]Post {
+	if n < 2 {
+		return nil
+	}
+	columns := make([][]Post, n)
+	heights := make([]int, n)
+	for _, p := range posts {
+		shortest := 0
+		for i := range heights {
+			if heights[i] < heights[shortest] {
+				shortest = i
+			}
+		}
+		columns[shortest] = append(columns[shortest], p)
+		heights[shortest] += estimatedHeight(p)
+	}
+	return columns
+}
+
+// estimatedHeight approximates a listing entry's height in lines.
+func estimatedHeight(p Post) int {
+	h := 2 + len(p.Excerpt)/80
+	if p.Cover != "" {
+		h += 4
+	}
+	return h
+}
+
+func analyticsSnippet() template.HTML {
+	a := config.Analytics
+	switch {
+	case a.Snippet != "":
+		return template.HTML(a.Snippet)
+	case a.Provider == "plausible" && a.SiteID != "":
+		src := a.ScriptURL
+		if src == "" {
+			src = "https://plausible.io/js/script.js"
+		}
+		return template.HTML(fmt.Sprintf(`<script defer data-domain="%s" src="%s"></script>`, html.EscapeString(a.SiteID), html.EscapeString(src)))
+	case a.Provider != "":
+		buildLog.Warnf("config", "unknown analytics provider %q", a.Provider)
+	}
+	return ""
+}
+
+// generateIndex renders index.html from the index.html template, with
+// config.PostsPerPage posts per page and further pages in page/.
+func generateIndex(posts []Post) error {
+	tmpl, err := loadTemplate("index.html")
+	if err != nil {
+		return err
+	}
+	sorted := sortPosts(posts, config.ListingSort)
+	perPage := config.PostsPerPage
+	if perPage <= 0 || perPage > len(sorted) {
+		perPage = max(len(sorted), 1)
+	}
+	pages := max((len(sorted)+perPage-1)/perPage, 1)
+	if pages > 1 {
+		if err := os.MkdirAll(filepath.Join(outputDir, "page"), 0755); err != nil {
+			return err
+		}
+	}
+	var errs []error
+	for page := 1; page <= pages; page++ {
+		onPage := sorted[(page-1)*perPage : min(page*perPage, len(sorted))]
+		// pages after the first live one directory down, the template sets
+		// <base href="{{.Root}}"> so links work the same on all of them
+		root, prev, next := "", "", ""
+		if page > 1 {
+			root = "../"
+			prev = indexPage(page - 1)
+		}
+		if page < pages {
+			next = indexPage(page + 1)
+		}
+		errs = append(errs, renderPage(tmpl, filepath.Join(outputDir, indexPage(page)), map[string]any{"Title": config.Title, "Posts": onPage, "Columns": splitColumns(onPage, config.IndexColumns), "Tools": config.Tools, "Links": config.Links, "Projects": config.Projects, "Slogan": config.Slogan, "Analytics": analyticsSnippet(),
+			"Page": page, "Pages": pages, "Root": root, "PrevPage": prev, "NextPage": next}))
+	}
+	return errors.Join(errs...)
+}
+
+// loadTemplate parses the template file name with funcMap.
+func loadTemplate(name string) (*template.Template, error) {
+	tpl, err := os.ReadFile(name)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	tmpl := template.Must(template.New("index").Funcs(funcMap).Parse(string(tpl)))
+	tmpl, err := template.New(name).Funcs(funcMap).Parse(string(tpl))
+	if err != nil {
+		return nil, fmt.Errorf("parsing %w", err)
+	}
+	return tmpl, nil
+}
+
+// renderPage executes tmpl with data and writes the result to path, leaving
+// an existing page alone when the template fails.
+func renderPage(tmpl *template.Template, path string, data any) error {
 	var buf bytes.Buffer
-	tmpl.Execute(&buf, map[string]any{"Title": config.Title, "Posts": posts, "Tools": config.Tools, "Links": config.Links, "Projects": config.Projects, "Slogan": config.Slogan})
-	_ = writeIfChanged("public/index.html", buf.Bytes())
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return fmt.Errorf("rendering %s: %w", path, err)
+	}
+	return writeIfChanged(path, buf.Bytes())
+}
+
+// indexPage returns the path of index page n below the output directory.
+func indexPage(n int) string {
+	if n == 1 {
+		return "index.html"
+	}
+	return "page/" + strconv.Itoa(n) + ".html"
 }
 
-func generatePosts(posts []Post) {
-	tpl, err := os.ReadFile("article.html")
+// generateUpdates renders updates.html from the listing.html template,
+// newest update first.
+func generateUpdates(posts []Post) error {
+	if !fileExists("listing.html") {
+		return nil
+	}
+	tmpl, err := loadTemplate("listing.html")
 	if err != nil {
-		panic(err)
+		return err
 	}
-	tmpl := template.Must(template.New("post").Funcs(funcMap).Parse(string(tpl)))
-	for _, post := range posts {
-		var buf bytes.Buffer
-		tmpl.Execute(&buf, struct {
-			Title   string
-			Slug    string
-			Date    time.Time
-			Content template.HTML
-			Slogan  string
-		}{
-			Title:   post.Title,
-			Slug:    post.Slug,
-			Date:    post.Date,
-			Content: template.HTML(post.Content),
-			Slogan:  config.Slogan,
-		})
-		_ = writeIfChanged("public/articles/"+post.Slug+".html", buf.Bytes())
-	}
-}
-
-func copyStaticAssets() {
-	input, err := os.ReadFile("style.css")
+	var updated []Post
+	for _, p := range sortPosts(posts, "updated") {
+		if config.UpdatesAll || p.Updated.After(p.Date) {
+			updated = append(updated, p)
+		}
+	}
+	return renderPage(tmpl, filepath.Join(outputDir, "updates.html"), map[string]any{"Title": config.Title, "Heading": "Updates", "Posts": updated, "Slogan": config.Slogan, "Analytics": analyticsSnippet()})
+}
+
+// generateAll renders every post on a single page from the all.html
+// template, oldest first, for offline reading or printing. Anchors within a
+// post are prefixed with its slug, so headings and footnotes of different
+// posts don't collide.
+func generateAll(posts []Post) error {
+	if !fileExists("all.html") {
+		return nil
+	}
+	tmpl, err := loadTemplate("all.html")
+	if err != nil {
+		return err
+	}
+	var chronological []Post
+	for i := len(posts) - 1; i >= 0; i-- {
+		p := posts[i]
+		// the content is written for the post's page, rebase its relative links
+		// and prefix its ids, which are only unique within the post
+		prefix := p.Slug + "-"
+		p.Content = template.HTML(rewriteLinks(string(p.Content), func(ref string) string {
+			if strings.HasPrefix(ref, "#") {
+				return "#" + prefix + ref[1:]
+			}
+			if isRelativeURL(ref) {
+				return path.Join(path.Dir(p.URL), ref)
+			}
+			return ref
+		}))
+		p.Content = template.HTML(idAttrRe.ReplaceAllString(string(p.Content), `id="`+prefix+`$1"`))
+		p.Headings, p.TOC = prefixIDs(p.Headings, prefix), prefixIDs(p.TOC, prefix)
+		chronological = append(chronological, p)
+	}
+	return renderPage(tmpl, filepath.Join(outputDir, "all.html"), map[string]any{"Title": config.Title, "Posts": chronological, "Slogan": config.Slogan, "Analytics": analyticsSnippet()})
+}
+
+var idAttrRe = regexp.MustCompile(`\bid="([^"]*)"`)
+
+func prefixIDs(headings []Heading, prefix string) []Heading {
+	prefixed := make([]Heading, len(headings))
+	for i, h := range headings {
+		h.ID = prefix + h.ID
+		prefixed[i] = h
+	}
+	return prefixed
+}
+
+// generatePosts renders the article pages on runtime.NumCPU() workers. The
+// progress lines are logged sorted once all pages are written, so build logs
+// stay comparable between runs. Pages newer than their source and the files
+// every page depends on are kept as they are.
+func generatePosts(posts []Post) error {
+	tmpl, err := loadTemplate("article.html")
+	if err != nil {
+		return err
+	}
+	// a change to any of these affects every page, as do the options below
+	deps := []string{"article.html", "style.css", configFile}
+	shared := time.Time{}
+	for _, dep := range deps {
+		if t := modTime(dep); t.After(shared) {
+			shared = t
+		}
+	}
+	// pages rendered with other options are out of date however new they are
+	options := optionsHash()
+	rerender := options != renderedOptions()
+	lines := make([]string, len(posts))
+	errs := make([]error, len(posts))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range runtime.NumCPU() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				post := posts[i]
+				file := postFile(post)
+				if out := modTime(file); !rerender && out.After(shared) && out.After(modTime(filepath.Join(inputDir, post.Source))) {
+					recordExisting(file)
+					lines[i] = "up to date: " + file
+					continue
+				}
+				var buf bytes.Buffer
+				err := tmpl.Execute(&buf, struct {
+					Post
+					Slogan    string
+					Analytics template.HTML
+					AMP       bool
+					AMPLink   string // relative to the page
+					Root      string // relative path back to the site root
+				}{
+					Post:      post,
+					Slogan:    config.Slogan,
+					Analytics: analyticsSnippet(),
+					AMP:       config.AMP,
+					AMPLink:   path.Base(ampURL(post)),
+					Root:      pageRoot(post.URL),
+				})
+				if err == nil {
+					err = os.MkdirAll(filepath.Dir(file), 0755)
+				}
+				status := "failed"
+				if err == nil {
+					status, err = writeOutput(file, buf.Bytes())
+				}
+				lines[i] = status + ": " + file
+				if err != nil {
+					errs[i] = fmt.Errorf("%s: %w", post.Source, err)
+				}
+			}
+		}()
+	}
+	for i := range posts {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	sort.Strings(lines)
+	for _, line := range lines {
+		buildLog.Printf("", "%s", line)
+	}
+	err = errors.Join(errs...)
 	if err == nil {
-		_ = writeIfChanged("public/style.css", input)
+		recordOptions(options)
 	}
+	return err
 }
 
-func generateSitemap(posts []Post) {
-	type URL struct {
-		Loc     string `xml:"loc"`
-		LastMod string `xml:"lastmod"`
+// staticDir is mirrored into the output as is, for favicons, fonts,
+// robots.txt, downloads and the like.
+const staticDir = "static"
+
+func copyStaticAssets() error {
+	var errs []error
+	if css := siteCSS(); css != nil && !config.InlineCSS {
+		errs = append(errs, writeIfChanged(filepath.Join(outputDir, "style.css"), css))
 	}
-	type Urlset struct {
-		XMLName xml.Name `xml:"urlset"`
-		Xmlns   string   `xml:"xmlns,attr"`
-		URLs    []URL    `xml:"url"`
+	filepath.WalkDir(staticDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, _ := filepath.Rel(staticDir, path)
+		dest := filepath.Join(outputDir, rel)
+		data, err := os.ReadFile(path)
+		if err == nil {
+			err = os.MkdirAll(filepath.Dir(dest), 0755)
+		}
+		if err != nil {
+			errs = append(errs, err)
+			return nil
+		}
+		status, err := storeOutput(dest, data)
+		buildLog.Printf("", "%s: %s", status, dest)
+		errs = append(errs, err)
+		return nil
+	})
+	return errors.Join(errs...)
+}
+
+// siteCSS returns style.css, minified with -minify, or nil if there is none.
+func siteCSS() []byte {
+	css, err := os.ReadFile("style.css")
+	if err != nil {
+		return nil
+	}
+	if minify {
+		css = minifyCSS(css)
+	}
+	return css
+}
+
+type sitemapURL struct {
+	Loc        string `xml:"loc"`
+	LastMod    string `xml:"lastmod"`
+	ChangeFreq string `xml:"changefreq,omitempty"`
+	Priority   string `xml:"priority,omitempty"`
+}
+
+// sitemap hints for the index and, unless their front matter says otherwise,
+// for posts
+const (
+	indexChangeFreq = "daily"
+	indexPriority   = "1.0"
+	postChangeFreq  = "monthly"
+	postPriority    = "0.5"
+)
+
+var changeFreqs = map[string]bool{"always": true, "hourly": true, "daily": true, "weekly": true, "monthly": true, "yearly": true, "never": true}
+
+// sitemapFiles are the outputs of the last generateSitemap call.
+var sitemapFiles []string
+
+// maxSitemapURLs is the most URLs the sitemap protocol allows in one file.
+const maxSitemapURLs = 50000
+
+// sitemapRef is an entry of a sitemap index.
+type sitemapRef struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// generateSitemap writes sitemap.xml, or, beyond maxSitemapURLs, numbered
+// sitemap-N.xml files referenced from sitemap-index.xml. With
+// config.SitemapSections it writes one sitemap per post section plus
+// sitemap-pages.xml for the remaining pages instead, turning sitemap.xml into
+// their index. It returns the files written.
+func generateSitemap(posts []Post) ([]string, error) {
+	index := sitemapURL{Loc: config.BaseURL + "/index.html", LastMod: latestDate(posts, "updated").Format("2006-01-02"), ChangeFreq: indexChangeFreq, Priority: indexPriority}
+	if !config.SitemapSections {
+		var urls []sitemapURL
+		for _, post := range posts {
+			urls = append(urls, postSitemapURL(post))
+		}
+		urls = append(urls, index)
+		if len(urls) <= maxSitemapURLs {
+			file := filepath.Join(outputDir, "sitemap.xml")
+			err := writeUrlset(file, urls)
+			removeStaleSitemaps()
+			return []string{file}, err
+		}
+		files, refs, err := writeSitemapChunks("sitemap", urls)
+		file := filepath.Join(outputDir, "sitemap-index.xml")
+		err = errors.Join(err, writeSitemapIndex(file, refs))
+		removeStaleSitemaps()
+		return append(files, file), err
 	}
-	var urls []URL
+
+	sections := map[string][]sitemapURL{}
 	for _, post := range posts {
-		urls = append(urls, URL{
-			Loc:     config.BaseURL + "/articles/" + post.Slug + ".html",
-			LastMod: post.Date.Format("2006-01-02"),
-		})
+		sections[post.Section] = append(sections[post.Section], postSitemapURL(post))
+	}
+	sections["pages"] = append(sections["pages"], index)
+	var names []string
+	for name := range sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var files []string
+	var refs []sitemapRef
+	var errs []error
+	for _, name := range names {
+		f, r, err := writeSitemapChunks("sitemap-"+name, sections[name])
+		files = append(files, f...)
+		refs = append(refs, r...)
+		errs = append(errs, err)
+	}
+	file := filepath.Join(outputDir, "sitemap.xml")
+	errs = append(errs, writeSitemapIndex(file, refs))
+	removeStaleSitemaps()
+	return append(files, file), errors.Join(errs...)
+}
+
+// removeStaleSitemaps removes sitemap files this build didn't write, left
+// by an earlier build with another split, so no index points to outdated
+// sitemaps and no outdated index is left behind.
+func removeStaleSitemaps() {
+	old, _ := filepath.Glob(filepath.Join(outputDir, "sitemap*.xml"))
+	for _, file := range old {
+		if !recorded(file) && os.Remove(file) == nil {
+			buildLog.Printf("", "removed: %s", file)
+		}
+	}
+}
+
+// writeSitemapChunks writes urls to <name>.xml, or split into <name>-1.xml,
+// <name>-2.xml, ... when they exceed maxSitemapURLs.
+func writeSitemapChunks(name string, urls []sitemapURL) (files []string, refs []sitemapRef, err error) {
+	chunks := [][]sitemapURL{urls}
+	if len(urls) > maxSitemapURLs {
+		chunks = nil
+		for i := 0; i < len(urls); i += maxSitemapURLs {
+			chunks = append(chunks, urls[i:min(i+maxSitemapURLs, len(urls))])
+		}
+	}
+	for i, chunk := range chunks {
+		file := name + ".xml"
+		if len(chunks) > 1 {
+			file = fmt.Sprintf("%s-%d.xml", name, i+1)
+		}
+		err = errors.Join(err, writeUrlset(filepath.Join(outputDir, file), chunk))
+		lastMod := ""
+		for _, u := range chunk {
+			if u.LastMod > lastMod {
+				lastMod = u.LastMod
+			}
+		}
+		files = append(files, filepath.Join(outputDir, file))
+		refs = append(refs, sitemapRef{Loc: config.BaseURL + "/" + file, LastMod: lastMod})
+	}
+	return files, refs, err
+}
+
+func writeSitemapIndex(path string, refs []sitemapRef) error {
+	type SitemapIndex struct {
+		XMLName  xml.Name     `xml:"sitemapindex"`
+		Xmlns    string       `xml:"xmlns,attr"`
+		Sitemaps []sitemapRef `xml:"sitemap"`
+	}
+	data, _ := xml.MarshalIndent(SitemapIndex{
+		Xmlns:    "http://www.sitemaps.org/schemas/sitemap/0.9",
+		Sitemaps: refs,
+	}, "", "  ")
+	return writeIfChanged(path, []byte(xml.Header+string(data)))
+}
+
+func postSitemapURL(post Post) sitemapURL {
+	return sitemapURL{
+		Loc:        config.BaseURL + "/" + post.URL,
+		LastMod:    post.Updated.Format("2006-01-02"),
+		ChangeFreq: post.ChangeFreq,
+		Priority:   post.Priority,
+	}
+}
+
+func writeUrlset(path string, urls []sitemapURL) error {
+	type Urlset struct {
+		XMLName xml.Name     `xml:"urlset"`
+		Xmlns   string       `xml:"xmlns,attr"`
+		URLs    []sitemapURL `xml:"url"`
 	}
-	urls = append(urls, URL{Loc: config.BaseURL + "/index.html", LastMod: time.Now().Format("2006-01-02")})
 	data, _ := xml.MarshalIndent(Urlset{
 		Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9",
 		URLs:  urls,
 	}, "", "  ")
-	_ = writeIfChanged("public/sitemap.xml", []byte(xml.Header+string(data)))
+	return writeIfChanged(path, []byte(xml.Header+string(data)))
 }
 
-func generateFeed(posts []Post) {
+func generateFeed(posts []Post) error {
 	var buf bytes.Buffer
 	buf.WriteString(`<?xml version="1.0" encoding="UTF-8" ?>
-<feed xmlns="http://www.w3.org/2005/Atom">
 `)
-	buf.WriteString(fmt.Sprintf("<title>%s</title>\n", config.Title))
+	if config.FeedReadingTime {
+		buf.WriteString(fmt.Sprintf("<feed xmlns=\"http://www.w3.org/2005/Atom\" xmlns:blog=\"%s/ns/blog\">\n", config.BaseURL))
+	} else {
+		buf.WriteString("<feed xmlns=\"http://www.w3.org/2005/Atom\">\n")
+	}
+	buf.WriteString(fmt.Sprintf("<title>%s</title>\n", html.EscapeString(config.Title)))
 	buf.WriteString(fmt.Sprintf("<link href=\"%s/feed.xml\" rel=\"self\" />\n", config.BaseURL))
 	buf.WriteString(fmt.Sprintf("<link href=\"%s\" />\n", config.BaseURL))
 	buf.WriteString(fmt.Sprintf("<id>%s/</id>\n", config.BaseURL))
-	buf.WriteString(fmt.Sprintf("<updated>%s</updated>\n", time.Now().Format(time.RFC3339)))
+	buf.WriteString(fmt.Sprintf("<updated>%s</updated>\n", latestDate(posts, config.FeedSort).Format(time.RFC3339)))
 	buf.WriteString("<author>\n")
 	buf.WriteString(fmt.Sprintf("  <name>%s</name>\n", config.Title))
 	buf.WriteString(fmt.Sprintf("  <uri>%s</uri>\n", config.BaseURL))
 	buf.WriteString("</author>\n")
-	for _, post := range posts {
+	for _, post := range feedPosts(posts) {
 		buf.WriteString("<entry>\n")
-		buf.WriteString(fmt.Sprintf("<title>%s</title>\n", post.Title))
-		buf.WriteString(fmt.Sprintf("<link href=\"%s/articles/%s.html\"/>\n", config.BaseURL, post.Slug))
-		buf.WriteString(fmt.Sprintf("<updated>%s</updated>\n", post.Date.Format(time.RFC3339)))
-		buf.WriteString(fmt.Sprintf("<id>%s/articles/%s.html</id>\n", config.BaseURL, post.Slug))
+		buf.WriteString(fmt.Sprintf("<title>%s</title>\n", html.EscapeString(post.Title)))
+		buf.WriteString(fmt.Sprintf("<link href=\"%s/%s\"/>\n", config.BaseURL, post.URL))
+		buf.WriteString(fmt.Sprintf("<published>%s</published>\n", post.Date.Format(time.RFC3339)))
+		buf.WriteString(fmt.Sprintf("<updated>%s</updated>\n", post.Updated.Format(time.RFC3339)))
+		buf.WriteString(fmt.Sprintf("<id>%s/%s</id>\n", config.BaseURL, post.URL))
+		if post.Cover != "" {
+			buf.WriteString(fmt.Sprintf("<link rel=\"enclosure\" type=\"%s\" href=\"%s\"/>\n", mime.TypeByExtension(path.Ext(post.Cover)), html.EscapeString(post.Cover)))
+		}
 		buf.WriteString("<author>\n")
-		buf.WriteString(fmt.Sprintf("  <name>%s</name>\n", config.Title))
-		buf.WriteString(fmt.Sprintf("  <uri>%s</uri>\n", config.BaseURL))
+		if post.Author != "" {
+			buf.WriteString(fmt.Sprintf("  <name>%s</name>\n", html.EscapeString(post.Author)))
+		} else {
+			buf.WriteString(fmt.Sprintf("  <name>%s</name>\n", config.Title))
+			buf.WriteString(fmt.Sprintf("  <uri>%s</uri>\n", config.BaseURL))
+		}
 		buf.WriteString("</author>\n")
-		buf.WriteString("<content type=\"html\">")
-		buf.WriteString(html.EscapeString(post.Excerpt))
+		if config.FullContentFeed {
+			// feed readers have no page URL to resolve relative links against
+			content := rewriteLinks(string(post.Content), func(ref string) string {
+				return absoluteURL(ref, "/"+post.URL)
+			})
+			buf.WriteString("<content type=\"html\">")
+			buf.WriteString(html.EscapeString(content))
+		} else {
+			buf.WriteString("<content type=\"text\">")
+			buf.WriteString(html.EscapeString(post.Excerpt))
+		}
 		buf.WriteString("</content>\n")
+		if config.FeedReadingTime {
+			buf.WriteString(fmt.Sprintf("<blog:readingTime>%d</blog:readingTime>\n", post.ReadingTime))
+			buf.WriteString(fmt.Sprintf("<blog:wordCount>%d</blog:wordCount>\n", post.WordCount))
+		}
 		buf.WriteString("</entry>\n")
 	}
 	buf.WriteString("</feed>")
-	_ = writeIfChanged("public/feed.xml", buf.Bytes())
+	return writeIfChanged(filepath.Join(outputDir, "feed.xml"), buf.Bytes())
+}
+
+// feedPosts returns the posts in feed order, capped at config.FeedMaxItems.
+func feedPosts(posts []Post) []Post {
+	sorted := sortPosts(posts, config.FeedSort)
+	if config.FeedMaxItems > 0 && len(sorted) > config.FeedMaxItems {
+		sorted = sorted[:config.FeedMaxItems]
+	}
+	return sorted
+}
+
+// generateRSS writes the same entries as generateFeed as an RSS 2.0 feed for
+// readers without Atom support.
+func generateRSS(posts []Post) error {
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8" ?>
+`)
+	buf.WriteString("<rss version=\"2.0\">\n")
+	buf.WriteString("<channel>\n")
+	buf.WriteString(fmt.Sprintf("<title>%s</title>\n", html.EscapeString(config.Title)))
+	buf.WriteString(fmt.Sprintf("<link>%s/</link>\n", config.BaseURL))
+	buf.WriteString(fmt.Sprintf("<description>%s</description>\n", html.EscapeString(config.Slogan)))
+	buf.WriteString(fmt.Sprintf("<lastBuildDate>%s</lastBuildDate>\n", latestDate(posts, config.FeedSort).Format(time.RFC1123Z)))
+	for _, post := range feedPosts(posts) {
+		link := config.BaseURL + "/" + post.URL
+		buf.WriteString("<item>\n")
+		buf.WriteString(fmt.Sprintf("<title>%s</title>\n", html.EscapeString(post.Title)))
+		buf.WriteString(fmt.Sprintf("<link>%s</link>\n", link))
+		buf.WriteString(fmt.Sprintf("<guid isPermaLink=\"true\">%s</guid>\n", link))
+		buf.WriteString(fmt.Sprintf("<pubDate>%s</pubDate>\n", post.Date.Format(time.RFC1123Z)))
+		buf.WriteString(fmt.Sprintf("<description>%s</description>\n", html.EscapeString(post.Excerpt)))
+		buf.WriteString("</item>\n")
+	}
+	buf.WriteString("</channel>\n")
+	buf.WriteString("</rss>")
+	return writeIfChanged(filepath.Join(outputDir, "rss.xml"), buf.Bytes())
+}
+
+func generateHumansTxt() error {
+	if config.Author == "" && len(config.Credits) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	buf.WriteString("/* TEAM */\n")
+	if config.Author != "" {
+		buf.WriteString(fmt.Sprintf("Author: %s\nSite: %s\n", config.Author, config.BaseURL))
+	}
+	if len(config.Credits) > 0 {
+		buf.WriteString("\n/* THANKS */\n")
+		for _, c := range config.Credits {
+			buf.WriteString(c + "\n")
+		}
+	}
+	return writeIfChanged(filepath.Join(outputDir, "humans.txt"), buf.Bytes())
+}
+
+func generateSecurityTxt() error {
+	if config.SecurityContact == "" {
+		return nil
+	}
+	expires, err := time.Parse("2006-01-02", config.SecurityExpires)
+	if err != nil {
+		return fmt.Errorf("skipping security.txt - SecurityExpires must be set as YYYY-MM-DD, got: %q", config.SecurityExpires)
+	}
+	if err := os.MkdirAll(filepath.Join(outputDir, ".well-known"), 0755); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("Contact: %s\n", config.SecurityContact))
+	buf.WriteString(fmt.Sprintf("Expires: %s\n", expires.UTC().Format(time.RFC3339)))
+	buf.WriteString(fmt.Sprintf("Canonical: %s/.well-known/security.txt\n", config.BaseURL))
+	return writeIfChanged(filepath.Join(outputDir, ".well-known", "security.txt"), buf.Bytes())
 }
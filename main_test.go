@@ -0,0 +1,1134 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"html"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testSite switches to a temporary directory holding files, keyed by their
+// slash separated path, and resets the configuration and build state to
+// defaults for the duration of the test. Log output is discarded.
+func testSite(t *testing.T, files map[string]string) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	for name, content := range files {
+		path := filepath.FromSlash(name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	savedConfig, savedLog := config, buildLog
+	t.Cleanup(func() {
+		config, buildLog = savedConfig, savedLog
+		inputDir, outputDir = "articles", "public"
+		minify, includeDrafts, includeFuture, showSchedule = false, false, false, false
+		lastPostsHash, sitemapFiles, postIndex = "", nil, nil
+	})
+	config = Config{Title: "Test", BaseURL: "https://example.com"}
+	buildLog = &logger{out: io.Discard, err: io.Discard}
+	inputDir, outputDir = "articles", "public"
+	lastPostsHash, sitemapFiles = "", nil
+}
+
+// readOutput returns the generated file at the slash separated path below
+// outputDir.
+func readOutput(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(outputDir, filepath.FromSlash(name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestExcerptSkipsLeadingImages(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"image then prose", "# Title\n\n![a photo](../images/a.png)\n\nThe first words.\n", "The first words."},
+		{"several images", "![a](a.png)\n\n![b](b.png) ![c](c.png)\n\nText after.\n", "Text after."},
+		{"image with text", "![a](a.png) and a caption\n", `<figure><img src="a.png" alt="a"><figcaption>a</figcaption></figure> and a caption`},
+		{"images only", "# Title\n\n![a](a.png)\n\n![b](b.png)\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, excerpt, _ := parseMarkdown(tt.input, "test.md")
+			if excerpt != tt.want {
+				t.Errorf("excerpt = %q, want %q", excerpt, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountWordsIgnoresMarkup(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int
+	}{
+		{"one two three", 3},
+		{"**bold** and _italic_ words", 4},
+		{"# A heading\n\n- item one\n- item two\n1. third item", 8},
+		{"see [the docs](https://example.com/a/very/long/url \"title\") now", 4},
+		{"![a photo](../images/x.png)", 2},
+		{"a [reference][ref] link\n\n[ref]: https://example.com", 3},
+		{"a note[^1] here\n\n[^1]: the note", 5},
+		{"| a | b |\n|---|---|\n| c | d |", 4},
+		{"before\n<!--more-->\nafter", 2},
+	}
+	for _, tt := range tests {
+		if got, _ := countWords(tt.input); got != tt.want {
+			t.Errorf("countWords(%q) = %d, want %d", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestFeedReadingTime(t *testing.T) {
+	// 450 words take three minutes at 200 words per minute
+	body := strings.Repeat("word ", 449) + "[last](https://example.com/not/a/word)"
+	testSite(t, map[string]string{"articles/2024-01-01-post.md": "# Post\n\n" + body + "\n"})
+	config.FeedReadingTime = true
+	posts, _, err := loadPosts(inputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(outputDir, 0755)
+	if err := generateFeed(posts); err != nil {
+		t.Fatal(err)
+	}
+	feed := readOutput(t, "feed.xml")
+	for _, want := range []string{`xmlns:blog="https://example.com/ns/blog"`, "<blog:readingTime>3</blog:readingTime>", "<blog:wordCount>451</blog:wordCount>"} {
+		if !strings.Contains(feed, want) {
+			t.Errorf("feed.xml lacks %s", want)
+		}
+	}
+
+	config.FeedReadingTime = false
+	if err := generateFeed(posts); err != nil {
+		t.Fatal(err)
+	}
+	if feed := readOutput(t, "feed.xml"); strings.Contains(feed, "blog:") {
+		t.Error("feed.xml has reading time elements although FeedReadingTime is off")
+	}
+}
+
+func TestReadingTimeCode(t *testing.T) {
+	prose := strings.Repeat("word ", 300) + "\n"
+	code := "\n```go\n" + strings.Repeat("fmt.Println(a, b, c, d)\n", 100) + "```\n"
+	tests := []struct {
+		name           string
+		codePerMinute  int
+		withoutMinutes int
+		withMinutes    int
+	}{
+		{"code skipped", 0, 2, 2},
+		{"code at 100 words per minute", 100, 2, 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := config
+			defer func() { config = saved }()
+			config.CodeWordsPerMinute = tt.codePerMinute
+			if got := readingTime(countWords(prose)); got != tt.withoutMinutes {
+				t.Errorf("without code: %d minutes, want %d", got, tt.withoutMinutes)
+			}
+			if got := readingTime(countWords(prose + code)); got != tt.withMinutes {
+				t.Errorf("with code: %d minutes, want %d", got, tt.withMinutes)
+			}
+		})
+	}
+}
+
+func TestSortPostsByUpdated(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	posts := []Post{
+		{Slug: "newest", Date: day(10), Updated: day(10)},
+		{Slug: "edited", Date: day(5), Updated: day(20)},
+		{Slug: "oldest", Date: day(1), Updated: day(1)},
+	}
+	tests := []struct {
+		by   string
+		want []string
+	}{
+		{"date", []string{"newest", "edited", "oldest"}},
+		{"", []string{"newest", "edited", "oldest"}},
+		{"updated", []string{"edited", "newest", "oldest"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, p := range sortPosts(posts, tt.by) {
+			got = append(got, p.Slug)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("sortPosts(%q) = %v, want %v", tt.by, got, tt.want)
+		}
+	}
+}
+
+func TestWriteOutputNormalizesLineEndings(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		keep    bool
+		content string
+		want    string
+	}{
+		{"html", "page.html", false, "<p>a</p>\r\n<p>b</p>\r<p>c</p>\n\n\n", "<p>a</p>\n<p>b</p>\n<p>c</p>\n"},
+		{"missing newline", "feed.xml", false, "<feed/>", "<feed/>\n"},
+		{"opted out", "page.html", true, "<p>a</p>\r\n", "<p>a</p>\r\n"},
+		{"binary", "image.png", false, "\x89PNG\r\n\x1a\n", "\x89PNG\r\n\x1a\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testSite(t, nil)
+			config.KeepLineEndings = tt.keep
+			if _, err := writeOutput(tt.file, []byte(tt.content)); err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := os.ReadFile(tt.file); string(got) != tt.want {
+				t.Errorf("wrote %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeneratedPageLineEndings(t *testing.T) {
+	testSite(t, map[string]string{
+		"article.html":                "<html>\r\n<body>{{.Content}}</body>\r\n</html>",
+		"articles/2024-01-01-post.md": "# Post\r\n\r\nText\r\n",
+	})
+	posts, _, err := loadPosts(inputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := generatePosts(posts); err != nil {
+		t.Fatal(err)
+	}
+	page := readOutput(t, "articles/2024-01-01-post.html")
+	if strings.Contains(page, "\r") || !strings.HasSuffix(page, "</html>\n") || strings.HasSuffix(page, "\n\n") {
+		t.Errorf("page not normalized: %q", page)
+	}
+}
+
+func TestFormatInlineMedia(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"![a song](../media/song.mp3)", `<figure><audio controls src="../media/song.mp3">a song</audio><figcaption>a song</figcaption></figure>`},
+		{"![a clip](clip.MP4)", `<figure><video controls src="clip.MP4">a clip</video><figcaption>a clip</figcaption></figure>`},
+		{"![a photo](photo.png)", `<figure><img src="photo.png" alt="a photo"><figcaption>a photo</figcaption></figure>`},
+	}
+	for _, tt := range tests {
+		if got := formatInline(tt.input); got != tt.want {
+			t.Errorf("formatInline(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestAdmonitionBlock(t *testing.T) {
+	input := "!!! warning Mind the *gap*\n    Some **bold** text\n    - item\n\nAfter"
+	tests := []struct {
+		name    string
+		enabled bool
+		want    string
+	}{
+		{"enabled", true, "<div class=\"admonition warning\">\n<p class=\"admonition-title\">Mind the <em>gap</em></p>\n<p>Some <strong>bold</strong> text</p>\n<ul>\n<li>item</li>\n</ul>\n</div>\n<p>After</p>\n"},
+		{"disabled", false, "<p>!!! warning Mind the <em>gap</em>\nSome <strong>bold</strong> text</p>\n<ul>\n<li>item</li>\n</ul>\n<p>After</p>\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := config
+			defer func() { config = saved }()
+			config.Admonitions = tt.enabled
+			if got, _, _, _ := parseMarkdown(input, "test.md"); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitHubAlerts(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"note", "> [!NOTE]\n> A *formatted* note\n> on two lines", "<div class=\"admonition note\">\n<p class=\"admonition-title\">Note</p>\n<p>A <em>formatted</em> note\non two lines</p>\n</div>\n"},
+		{"lower case tip", "> [!tip]\n> Try it", "<div class=\"admonition tip\">\n<p class=\"admonition-title\">Tip</p>\n<p>Try it</p>\n</div>\n"},
+		{"unknown type", "> [!FOO]\n> body", "<blockquote><p>[!FOO]</p><p>body</p></blockquote>\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _, _, _ := parseMarkdown(tt.input, "test.md"); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCoverImage(t *testing.T) {
+	testSite(t, map[string]string{
+		"index.html":                  `{{range .Posts}}<img class="thumbnail" src="{{.Cover}}">{{end}}`,
+		"article.html":                `{{if .Cover}}<meta property="og:image" content="{{.Cover}}" />{{end}}`,
+		"articles/2024-01-01-post.md": "---\ncover: ../images/cover.png\n---\n# Post\n\nText\n",
+	})
+	if err := buildSite(); err != nil {
+		t.Fatal(err)
+	}
+	const cover = "https://example.com/images/cover.png"
+	if got := readOutput(t, "index.html"); !strings.Contains(got, `src="`+cover+`"`) {
+		t.Errorf("index.html lacks the cover: %s", got)
+	}
+	if got := readOutput(t, "articles/2024-01-01-post.html"); !strings.Contains(got, `<meta property="og:image" content="`+cover+`" />`) {
+		t.Errorf("article lacks the og:image: %s", got)
+	}
+	if got := readOutput(t, "feed.xml"); !strings.Contains(got, `<link rel="enclosure" type="image/png" href="`+cover+`"/>`) {
+		t.Errorf("feed.xml lacks the enclosure: %s", got)
+	}
+}
+
+func TestExcerptSentences(t *testing.T) {
+	paragraph := "<p>First one, e.g. with an abbreviation. Second <em>one</em>! Third? Fourth.</p>"
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{"sentences:1", "First one, e.g. with an abbreviation."},
+		{"sentences:2", "First one, e.g. with an abbreviation. Second one!"},
+		{"sentences:9", "First one, e.g. with an abbreviation. Second one! Third? Fourth."},
+		{"paragraph", paragraph},
+	}
+	for _, tt := range tests {
+		if got := applyExcerptMode(paragraph, tt.mode); got != tt.want {
+			t.Errorf("applyExcerptMode(%q) = %q, want %q", tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestHumansAndSecurityTxt(t *testing.T) {
+	testSite(t, nil)
+	os.MkdirAll(outputDir, 0755)
+	if err := errors.Join(generateHumansTxt(), generateSecurityTxt()); err != nil {
+		t.Fatal(err)
+	}
+	if fileExists(filepath.Join(outputDir, "humans.txt")) || fileExists(filepath.Join(outputDir, ".well-known", "security.txt")) {
+		t.Error("files written without configuration")
+	}
+
+	config.Author = "Jane Doe"
+	config.Credits = []string{"Thanks: everyone"}
+	config.SecurityContact = "mailto:security@example.com"
+	config.SecurityExpires = "2030-01-31"
+	if err := errors.Join(generateHumansTxt(), generateSecurityTxt()); err != nil {
+		t.Fatal(err)
+	}
+	humans := readOutput(t, "humans.txt")
+	for _, want := range []string{"Author: Jane Doe\n", "Site: https://example.com\n", "/* THANKS */\nThanks: everyone\n"} {
+		if !strings.Contains(humans, want) {
+			t.Errorf("humans.txt lacks %q:\n%s", want, humans)
+		}
+	}
+	security := readOutput(t, ".well-known/security.txt")
+	for _, want := range []string{"Contact: mailto:security@example.com\n", "Expires: 2030-01-31T00:00:00Z\n", "Canonical: https://example.com/.well-known/security.txt\n"} {
+		if !strings.Contains(security, want) {
+			t.Errorf("security.txt lacks %q:\n%s", want, security)
+		}
+	}
+}
+
+func TestFeedSkippedWhenPostsUnchanged(t *testing.T) {
+	testSite(t, map[string]string{
+		"index.html":                  "{{range .Posts}}{{.Title}}{{end}}",
+		"article.html":                "{{.Content}}",
+		"style.css":                   "body {}",
+		"articles/2024-01-01-post.md": "# Post\n\nText\n",
+	})
+	if err := buildSite(); err != nil {
+		t.Fatal(err)
+	}
+	feed := filepath.Join(outputDir, "feed.xml")
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(feed, old, old)
+
+	var log bytes.Buffer
+	buildLog = &logger{out: &log, err: &log}
+	os.WriteFile("style.css", []byte("body { color: black }"), 0644)
+	if err := buildSite(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(log.String(), "feed.xml") || modTime(feed).After(old.Add(time.Second)) {
+		t.Errorf("feed.xml was regenerated for a style change:\n%s", log.String())
+	}
+	if !strings.Contains(readOutput(t, ".manifest.json"), `"feed.xml"`) {
+		t.Error("a skipped feed.xml is missing from the manifest")
+	}
+
+	log.Reset()
+	os.WriteFile(filepath.Join(inputDir, "2024-01-01-post.md"), []byte("# Post\n\nNew text\n"), 0644)
+	if err := buildSite(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(log.String(), "writing: "+feed) {
+		t.Errorf("feed.xml was not regenerated for a changed post:\n%s", log.String())
+	}
+}
+
+func TestLinkPostKind(t *testing.T) {
+	testSite(t, map[string]string{
+		"index.html":                  `{{range .Posts}}<li class="{{.Kind}}">{{if eq .Kind "link"}}<a href="{{.LinkURL}}">{{.Title}} ↗</a>{{else}}<a href="{{.URL}}">{{.Title}}</a>{{end}}</li>{{end}}`,
+		"article.html":                "{{.Content}}",
+		"articles/2024-01-02-link.md": "---\nkind: link\nlink_url: https://go.dev/blog/\n---\n# Go blog\n\nWorth reading.\n",
+		"articles/2024-01-01-post.md": "# Post\n\nText\n",
+	})
+	if err := buildSite(); err != nil {
+		t.Fatal(err)
+	}
+	want := `<li class="link"><a href="https://go.dev/blog/">Go blog ↗</a></li><li class="article"><a href="articles/2024-01-01-post.html">Post</a></li>`
+	if got := readOutput(t, "index.html"); strings.TrimSpace(got) != want {
+		t.Errorf("index.html = %q, want %q", got, want)
+	}
+}
+
+func TestCRLFMarkdown(t *testing.T) {
+	testSite(t, map[string]string{
+		"articles/2024-01-01-crlf.md": "# Title\r\n\r\nSome text\r\n\r\n```go\r\nfmt.Println(1)\r\n```\r\n\r\n- item\r",
+	})
+	posts, _, err := loadPosts(inputDir)
+	if err != nil || len(posts) != 1 {
+		t.Fatal(posts, err)
+	}
+	content := string(posts[0].Content)
+	if strings.Contains(content, "\r") {
+		t.Errorf("content has a carriage return: %q", content)
+	}
+	for _, want := range []string{"<h1>Title</h1>\n", "<p>Some text</p>\n", "<pre><code class=\"language-go\">fmt.Println(1)\n</code></pre>", "<li>item</li>"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("content lacks %q: %q", want, content)
+		}
+	}
+}
+
+func TestExcerptFrontMatter(t *testing.T) {
+	testSite(t, map[string]string{
+		"index.html":                  "{{range .Posts}}{{.ExcerptHTML}}{{end}}",
+		"article.html":                "{{.Content}}",
+		"articles/2024-01-01-post.md": "---\nexcerpt: A *short* [teaser](https://example.org/)\n---\n# Post\n\nThe first paragraph.\n",
+	})
+	if err := buildSite(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.TrimSpace(readOutput(t, "index.html")), `A <em>short</em> <a href="https://example.org/">teaser</a>`; got != want {
+		t.Errorf("index.html = %q, want %q", got, want)
+	}
+	if got := readOutput(t, "feed.xml"); !strings.Contains(got, `<content type="text">A short teaser</content>`) {
+		t.Errorf("feed.xml lacks the plain excerpt:\n%s", got)
+	}
+}
+
+func TestAnalyticsOnlyWhenConfigured(t *testing.T) {
+	tests := []struct {
+		name      string
+		analytics Analytics
+		want      string
+	}{
+		{"unset", Analytics{}, ""},
+		{"plausible", Analytics{Provider: "plausible", SiteID: "example.com"}, `<script defer data-domain="example.com" src="https://plausible.io/js/script.js"></script>`},
+		{"self-hosted", Analytics{Provider: "plausible", SiteID: "example.com", ScriptURL: "https://stats.example.com/js/script.js"}, `<script defer data-domain="example.com" src="https://stats.example.com/js/script.js"></script>`},
+		{"snippet", Analytics{Snippet: `<script src="/count.js"></script>`}, `<script src="/count.js"></script>`},
+		{"unknown provider", Analytics{Provider: "other", SiteID: "example.com"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testSite(t, map[string]string{
+				"index.html":                  "<head>{{.Analytics}}</head>",
+				"article.html":                "<head>{{.Analytics}}</head>",
+				"articles/2024-01-01-post.md": "# Post\n\nText\n",
+			})
+			config.Analytics = tt.analytics
+			if err := buildSite(); err != nil {
+				t.Fatal(err)
+			}
+			for _, page := range []string{"index.html", "articles/2024-01-01-post.html"} {
+				if got := readOutput(t, page); got != "<head>"+tt.want+"</head>\n" {
+					t.Errorf("%s = %q, want the snippet %q", page, got, tt.want)
+				}
+			}
+		})
+	}
+	if compiledConfig.Analytics != (Analytics{}) {
+		t.Errorf("compiled default analytics %+v, want none", compiledConfig.Analytics)
+	}
+}
+
+func TestSplitColumns(t *testing.T) {
+	var posts []Post
+	for i := range 10 {
+		posts = append(posts, Post{Slug: strconv.Itoa(i), Excerpt: strings.Repeat("x", 80*(i%3))})
+	}
+	for _, n := range []int{2, 3, 4} {
+		columns := splitColumns(posts, n)
+		if len(columns) != n {
+			t.Fatalf("%d columns, want %d", len(columns), n)
+		}
+		minHeight, maxHeight, total := math.MaxInt, 0, 0
+		for _, column := range columns {
+			height := 0
+			for i, p := range column {
+				height += estimatedHeight(p)
+				if i > 0 && p.Slug < column[i-1].Slug {
+					t.Errorf("%d columns: order not preserved in %v", n, column)
+				}
+			}
+			minHeight, maxHeight, total = min(minHeight, height), max(maxHeight, height), total+len(column)
+		}
+		if total != len(posts) {
+			t.Errorf("%d columns hold %d posts, want %d", n, total, len(posts))
+		}
+		// balanced up to the tallest single entry
+		if maxHeight-minHeight > 4 {
+			t.Errorf("%d columns: heights range from %d to %d", n, minHeight, maxHeight)
+		}
+	}
+	if columns := splitColumns(posts, 1); columns != nil {
+		t.Errorf("a single column should not be split, got %d", len(columns))
+	}
+}
+
+func TestUpdatesPage(t *testing.T) {
+	testSite(t, map[string]string{
+		"index.html":                   "",
+		"article.html":                 "",
+		"listing.html":                 "{{range .Posts}}{{.Slug}} {{.Updated.Format \"2006-01-02\"}}\n{{end}}",
+		"articles/2024-01-01-old.md":   "---\nupdated: 2024-03-01\n---\n# Old\n",
+		"articles/2024-01-05-plain.md": "# Never updated\n",
+		"articles/2024-01-10-newer.md": "---\nupdated: 2024-02-01\n---\n# Newer\n",
+	})
+	tests := []struct {
+		all  bool
+		want string
+	}{
+		{false, "2024-01-01-old 2024-03-01\n2024-01-10-newer 2024-02-01\n"},
+		{true, "2024-01-01-old 2024-03-01\n2024-01-10-newer 2024-02-01\n2024-01-05-plain 2024-01-05\n"},
+	}
+	for _, tt := range tests {
+		config.UpdatesAll = tt.all
+		if err := buildSite(); err != nil {
+			t.Fatal(err)
+		}
+		if got := readOutput(t, "updates.html"); got != tt.want {
+			t.Errorf("UpdatesAll %v: updates.html = %q, want %q", tt.all, got, tt.want)
+		}
+	}
+}
+
+func TestFeedSort(t *testing.T) {
+	testSite(t, map[string]string{
+		// the older post was edited after the newer one was published
+		"articles/2024-01-01-edited.md": "---\nupdated: 2024-02-01\n---\n# Edited\n",
+		"articles/2024-01-10-newer.md":  "# Newer\n",
+	})
+	posts, _, err := loadPosts(inputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(outputDir, 0755)
+	tests := []struct {
+		sort        string
+		first       string
+		updated     string
+		lastBuildAt string
+	}{
+		{"", "2024-01-10-newer", "2024-01-10T00:00:00Z", "Wed, 10 Jan 2024"},
+		{"published", "2024-01-10-newer", "2024-01-10T00:00:00Z", "Wed, 10 Jan 2024"},
+		{"updated", "2024-01-01-edited", "2024-02-01T00:00:00Z", "Thu, 01 Feb 2024"},
+	}
+	for _, tt := range tests {
+		config.FeedSort = tt.sort
+		if err := errors.Join(generateFeed(posts), generateRSS(posts)); err != nil {
+			t.Fatal(err)
+		}
+		feed, rss := readOutput(t, "feed.xml"), readOutput(t, "rss.xml")
+		if _, entries, _ := strings.Cut(feed, "<entry>"); !strings.Contains(entries[:strings.Index(entries, "</entry>")], tt.first) {
+			t.Errorf("FeedSort %q: first Atom entry is not %s", tt.sort, tt.first)
+		}
+		if _, items, _ := strings.Cut(rss, "<item>"); !strings.Contains(items[:strings.Index(items, "</item>")], tt.first) {
+			t.Errorf("FeedSort %q: first RSS item is not %s", tt.sort, tt.first)
+		}
+		if head, _, _ := strings.Cut(feed, "<entry>"); !strings.Contains(head, "<updated>"+tt.updated+"</updated>") {
+			t.Errorf("FeedSort %q: feed <updated> is not %s:\n%s", tt.sort, tt.updated, head)
+		}
+		if !strings.Contains(rss, "<lastBuildDate>"+tt.lastBuildAt) {
+			t.Errorf("FeedSort %q: <lastBuildDate> is not %s", tt.sort, tt.lastBuildAt)
+		}
+	}
+}
+
+func TestFormatInline(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"==marked==", "<mark>marked</mark>"},
+		{"some ==highlighted words== in a sentence", "some <mark>highlighted words</mark> in a sentence"},
+		{"==a== and ==b==", "<mark>a</mark> and <mark>b</mark>"},
+		{"(==a==)", "(<mark>a</mark>)"},
+		{"`a ==b== c`", "<code>a ==b== c</code>"},
+		{"if x==y==z", "if x==y==z"},
+		{"[a](http://x.com/a==b==c)", `<a href="http://x.com/a==b==c">a</a>`},
+		{"[**bold** link](https://example.com/*x*)", `<a href="https://example.com/*x*"><strong>bold</strong> link</a>`},
+		{`[a](https://example.com "the **title**")`, `<a href="https://example.com" title="the **title**">a</a>`},
+		{"H~2~O", "H<sub>2</sub>O"},
+		{"x^2^ + y^2^", "x<sup>2</sup> + y<sup>2</sup>"},
+		{"~~struck~~ and C~6~H~12~O~6~", "<del>struck</del> and C<sub>6</sub>H<sub>12</sub>O<sub>6</sub>"},
+		{"`H~2~O`", "<code>H~2~O</code>"},
+		{"cp ~/a~ b", "cp ~/a~ b"},
+		{"[a](http://x.edu/~bob/~x)", `<a href="http://x.edu/~bob/~x">a</a>`},
+		{"see http://x.edu/~bob/~x", "see http://x.edu/~bob/~x"},
+		{"http://x.org/~a~b and H~2~O", "http://x.org/~a~b and H<sub>2</sub>O"},
+		{"![a](images/_draft_.png)", `<figure><img src="images/_draft_.png" alt="a"><figcaption>a</figcaption></figure>`},
+		{"[x](https://example.com/_private_/page)", `<a href="https://example.com/_private_/page">x</a>`},
+		{"snake_case and some_var_name", "snake_case and some_var_name"},
+		{"see https://example.com/_private_/page and _this_", "see https://example.com/_private_/page and <em>this</em>"},
+		{"a * b = c * d", "a * b = c * d"},
+		{"2 * 3 * 4", "2 * 3 * 4"},
+		{"*a* and *b* and **c** and **d**", "<em>a</em> and <em>b</em> and <strong>c</strong> and <strong>d</strong>"},
+		{"***both***", "<strong><em>both</em></strong>"},
+		{"**bold***italic*", "<strong>bold</strong><em>italic</em>"},
+		{"`[a](b)`", "<code>[a](b)</code>"},
+		{"`**x**` and `![a](b.png)`", "<code>**x**</code> and <code>![a](b.png)</code>"},
+		{"`https://x.com/_a_`", "<code>https://x.com/_a_</code>"},
+		{"**`code`**", "<strong><code>code</code></strong>"},
+	}
+	for _, tt := range tests {
+		if got := formatInline(tt.input); got != tt.want {
+			t.Errorf("formatInline(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestInlineCodeClass(t *testing.T) {
+	tests := []struct {
+		name  string
+		class string
+		want  string
+	}{
+		{"configured", "inline-code", "use <code class=\"inline-code\">go test</code> and <code class=\"inline-code\">a`b</code>"},
+		{"default", "", "use <code>go test</code> and <code>a`b</code>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := config
+			defer func() { config = saved }()
+			config.InlineCodeClass = tt.class
+			if got := formatInline("use `go test` and ``a`b``"); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			// fenced code keeps only its language class
+			got, _, _, _ := parseMarkdown("```go\nx\n```\n", "test.md")
+			if !strings.Contains(got, "<pre><code class=\"language-go\">x\n</code></pre>") {
+				t.Errorf("fenced code = %q, want only the language class", got)
+			}
+		})
+	}
+}
+
+func TestScheduleReport(t *testing.T) {
+	future := time.Now().AddDate(0, 0, 10).Format("2006-01-02")
+	testSite(t, map[string]string{
+		"index.html":                          "{{range .Posts}}{{.Slug}}{{end}}",
+		"article.html":                        "{{.Content}}",
+		"articles/2024-01-01-published.md":    "# Published\n",
+		"articles/" + future + "-upcoming.md": "# Upcoming post\n",
+	})
+	var log bytes.Buffer
+	buildLog = &logger{out: &log, err: &log}
+	showSchedule = true
+	if err := buildSite(); err != nil {
+		t.Fatal(err)
+	}
+	if want := "  " + future + "  Upcoming post (" + future + "-upcoming)"; !strings.Contains(log.String(), want) {
+		t.Errorf("schedule report lacks %q:\n%s", want, log.String())
+	}
+	if strings.Contains(log.String(), "Published (") {
+		t.Error("schedule report lists a published post")
+	}
+	if got := readOutput(t, "index.html"); strings.Contains(got, "upcoming") {
+		t.Errorf("future post is on the index: %s", got)
+	}
+}
+
+func TestExcerptFallback(t *testing.T) {
+	tests := []struct {
+		fallback string
+		want     string
+	}{
+		{"", ""},
+		{"title", "Holiday photos"},
+		{"Photos & more", "Photos &amp; more"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fallback, func(t *testing.T) {
+			testSite(t, map[string]string{
+				"articles/2024-01-01-photos.md": "# Holiday photos\n\n![beach](beach.png)\n\n![sea](sea.mp4)\n",
+			})
+			config.ExcerptFallback = tt.fallback
+			posts, _, err := loadPosts(inputDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(posts[0].ExcerptHTML); got != tt.want {
+				t.Errorf("ExcerptHTML = %q, want %q", got, tt.want)
+			}
+			if got := posts[0].Excerpt; got != html.UnescapeString(tt.want) {
+				t.Errorf("Excerpt = %q, want %q", got, html.UnescapeString(tt.want))
+			}
+		})
+	}
+}
+
+func TestAllPostsPage(t *testing.T) {
+	testSite(t, map[string]string{
+		"index.html":                    "",
+		"article.html":                  "",
+		"all.html":                      "{{range .Posts}}<article id=\"{{.Slug}}\">{{range .TOC}}<a href=\"#{{.ID}}\">{{.Text}}</a>{{end}}{{.Content}}</article>\n{{end}}",
+		"articles/2024-01-01-first.md":  "# First\n\nFirst text[^1], see [the second](2024-01-02-second.html).\n\n## Conclusion\n\n[^1]: A note.\n",
+		"articles/2024-01-02-second.md": "# Second\n\nSecond text[^1] and [below](#conclusion).\n\n## Conclusion\n\n[^1]: Another note.\n",
+		"articles/2024-01-03-draft.md":  "---\ndraft: true\n---\n# Draft\n",
+	})
+	if err := buildSite(); err != nil {
+		t.Fatal(err)
+	}
+	page := readOutput(t, "all.html")
+	first, second := strings.Index(page, "First text"), strings.Index(page, "Second text")
+	if first < 0 || second < 0 || first > second {
+		t.Errorf("posts missing or not oldest first:\n%s", page)
+	}
+	if strings.Contains(page, "Draft") {
+		t.Error("all.html contains a draft")
+	}
+	ids := map[string]bool{}
+	for _, m := range idAttrRe.FindAllStringSubmatch(page, -1) {
+		if ids[m[1]] {
+			t.Errorf("duplicate id %q", m[1])
+		}
+		ids[m[1]] = true
+	}
+	for _, m := range regexp.MustCompile(`href="#([^"]*)"`).FindAllStringSubmatch(page, -1) {
+		if !ids[m[1]] {
+			t.Errorf("link to missing anchor #%s", m[1])
+		}
+	}
+	for _, want := range []string{`id="2024-01-02-second-conclusion"`, `href="#2024-01-02-second-conclusion"`, `href="articles/2024-01-02-second.html"`} {
+		if !strings.Contains(page, want) {
+			t.Errorf("all.html lacks %s", want)
+		}
+	}
+}
+
+func TestExcerptSkipsHeadings(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"subheading first", "## Introduction\n\nThe actual prose.\n", "The actual prose."},
+		{"title and subheadings", "# Title\n\n## Part one\n\n### Background\n\nFirst prose.\n\nSecond prose.\n", "First prose."},
+		{"setext headings and rule", "Title\n=====\n\nPart\n----\n\n***\n\nProse after.\n", "Prose after."},
+		{"no prose", "# Title\n\n## Only headings\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, excerpt, _ := parseMarkdown(tt.input, "test.md"); excerpt != tt.want {
+				t.Errorf("excerpt = %q, want %q", excerpt, tt.want)
+			}
+		})
+	}
+}
+
+func TestLayoutClass(t *testing.T) {
+	tests := []struct {
+		base   string
+		layout string
+		want   string
+	}{
+		{"", "", "<article>"},
+		{"", "wide", `<article class="wide">`},
+		{"post", "", `<article class="post">`},
+		{"post", "full-bleed", `<article class="post full-bleed">`},
+		{"post", `x" onclick="y`, `<article class="post x--onclick--y">`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.base+"/"+tt.layout, func(t *testing.T) {
+			meta := ""
+			if tt.layout != "" {
+				meta = "---\nlayout: " + tt.layout + "\n---\n"
+			}
+			testSite(t, map[string]string{
+				"index.html":                  "",
+				"article.html":                `<article{{if .LayoutClass}} class="{{.LayoutClass}}"{{end}}>`,
+				"articles/2024-01-01-post.md": meta + "# Post\n",
+			})
+			config.ArticleClass = tt.base
+			if err := buildSite(); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(readOutput(t, "articles/2024-01-01-post.html")); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeadingsInTemplate(t *testing.T) {
+	testSite(t, map[string]string{
+		"index.html":                  "",
+		"article.html":                "{{range .Headings}}{{.Level}} {{.ID}} {{.Text}}\n{{end}}",
+		"articles/2024-01-01-post.md": "# Title\n\n## Setup\n\ntext\n\n### Install *it*\n\nUnderlined\n----------\n\n#### Deep\n\n## Setup\n",
+	})
+	if err := buildSite(); err != nil {
+		t.Fatal(err)
+	}
+	want := "2 setup Setup\n3 install--it- Install it\n2 underlined Underlined\n4 deep Deep\n2 setup-2 Setup\n"
+	if got := readOutput(t, "articles/2024-01-01-post.html"); got != want {
+		t.Errorf("headings = %q, want %q", got, want)
+	}
+}
+
+func TestTOCDepth(t *testing.T) {
+	tests := []struct {
+		depth int
+		want  string
+	}{
+		{1, "setup underlined setup-2 \n"},
+		{0, "setup install--it- underlined setup-2 \n"},
+		{2, "setup install--it- underlined setup-2 \n"},
+		{3, "setup install--it- underlined deep setup-2 \n"},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.depth), func(t *testing.T) {
+			testSite(t, map[string]string{
+				"index.html":                  "",
+				"article.html":                "{{range .TOC}}{{.ID}} {{end}}",
+				"articles/2024-01-01-post.md": "# Title\n\n## Setup\n\ntext\n\n### Install *it*\n\nUnderlined\n----------\n\n#### Deep\n\n## Setup\n",
+			})
+			config.TOCMaxDepth = tt.depth
+			if err := buildSite(); err != nil {
+				t.Fatal(err)
+			}
+			if got := readOutput(t, "articles/2024-01-01-post.html"); got != tt.want {
+				t.Errorf("TOC = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmphasizedTitle(t *testing.T) {
+	tests := []struct {
+		name string
+		post string
+	}{
+		{"heading", "# Hello *World* & `code`\n\nText\n"},
+		{"front matter", "---\ntitle: Hello *World* & `code`\n---\nText\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testSite(t, map[string]string{
+				"index.html":                  "",
+				"article.html":                "<title>{{.Title}}</title>\n{{.Content}}",
+				"articles/2024-01-01-post.md": tt.post,
+			})
+			if err := buildSite(); err != nil {
+				t.Fatal(err)
+			}
+			page := readOutput(t, "articles/2024-01-01-post.html")
+			for _, want := range []string{"<title>Hello World &amp; code</title>", "<h1>Hello <em>World</em> &amp; <code>code</code></h1>"} {
+				if !strings.Contains(page, want) {
+					t.Errorf("page lacks %s:\n%s", want, page)
+				}
+			}
+			for _, feed := range []string{"feed.xml", "rss.xml"} {
+				if got := readOutput(t, feed); !strings.Contains(got, "<title>Hello World &amp; code</title>") {
+					t.Errorf("%s lacks the plain title:\n%s", feed, got)
+				}
+			}
+		})
+	}
+}
+
+func TestRebuildSerializes(t *testing.T) {
+	defer func(f func() error) { buildFunc = f }(buildFunc)
+	testSite(t, nil)
+	started, release := make(chan struct{}), make(chan struct{})
+	var running, calls, overlaps atomic.Int32
+	buildFunc = func() error {
+		if running.Add(1) > 1 {
+			overlaps.Add(1)
+		}
+		started <- struct{}{}
+		<-release
+		running.Add(-1)
+		calls.Add(1)
+		return nil
+	}
+	done := make(chan struct{})
+	go func() {
+		rebuild()
+		close(done)
+	}()
+	<-started
+	// triggers while building are folded into a single pending rebuild
+	for range 3 {
+		rebuild()
+	}
+	release <- struct{}{}
+	<-started
+	release <- struct{}{}
+	<-done
+	if got := calls.Load(); got != 2 {
+		t.Errorf("%d builds, want 2", got)
+	}
+	if overlaps.Load() > 0 {
+		t.Error("builds overlapped")
+	}
+	// the guard is released afterwards
+	done = make(chan struct{})
+	go func() {
+		rebuild()
+		close(done)
+	}()
+	<-started
+	release <- struct{}{}
+	<-done
+}
+
+func TestSitemapSections(t *testing.T) {
+	testSite(t, map[string]string{
+		"index.html":                  "",
+		"article.html":                "{{.Content}}",
+		"articles/2024-01-01-post.md": "---\nsection: essays\n---\n# Post\n\nText\n",
+		"articles/2024-02-01-note.md": "---\nsection: notes\n---\n# Note\n\nText\n",
+		// left by an earlier build that split by size
+		"public/sitemap-index.xml": "<sitemapindex/>",
+		"public/sitemap-2.xml":     "<urlset/>",
+	})
+	config.SitemapSections = true
+	if err := buildSite(); err != nil {
+		t.Fatal(err)
+	}
+	index := readOutput(t, "sitemap.xml")
+	for _, section := range []struct{ name, loc string }{
+		{"essays", "/articles/2024-01-01-post.html"},
+		{"notes", "/articles/2024-02-01-note.html"},
+		{"pages", "/index.html"},
+	} {
+		if !strings.Contains(index, "<loc>https://example.com/sitemap-"+section.name+".xml</loc>") {
+			t.Errorf("sitemap.xml lacks the %s sitemap:\n%s", section.name, index)
+		}
+		if got := readOutput(t, "sitemap-"+section.name+".xml"); strings.Count(got, "<loc>") != 1 || !strings.Contains(got, "<loc>https://example.com"+section.loc+"</loc>") {
+			t.Errorf("sitemap-%s.xml should only list %s:\n%s", section.name, section.loc, got)
+		}
+	}
+	if !strings.Contains(index, "<sitemapindex") || strings.Contains(index, "<urlset") {
+		t.Errorf("sitemap.xml is not an index:\n%s", index)
+	}
+
+	// turned off again, the section sitemaps go and sitemap.xml lists the pages
+	config.SitemapSections = false
+	lastPostsHash = ""
+	if err := buildSite(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readOutput(t, "sitemap.xml"); strings.Count(got, "<loc>") != 3 || !strings.Contains(got, "<urlset") {
+		t.Errorf("sitemap.xml should list all pages:\n%s", got)
+	}
+	stale, _ := filepath.Glob(filepath.Join(outputDir, "sitemap-*.xml"))
+	if len(stale) > 0 {
+		t.Errorf("stale sitemaps left: %v", stale)
+	}
+}
+
+func TestSameDatePostOrder(t *testing.T) {
+	testSite(t, map[string]string{
+		"articles/2024-03-01-b.md": "# B\n\nText\n",
+		"articles/2024-03-01-c.md": "# C\n\nText\n",
+		"articles/a.md":            "---\ndate: 2024-03-01\n---\n# A\n\nText\n",
+		"articles/2024-04-01-d.md": "# D\n\nText\n",
+	})
+	// the same day by filename
+	want := []string{"2024-04-01-d", "2024-03-01-b", "2024-03-01-c", "a"}
+	for range 10 {
+		posts, _, err := loadPosts(inputDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, p := range posts {
+			got = append(got, p.Slug)
+		}
+		if !slices.Equal(got, want) {
+			t.Fatalf("order %v, want %v", got, want)
+		}
+	}
+}
+
+func TestPostTemplateFunc(t *testing.T) {
+	testSite(t, map[string]string{
+		"index.html":                   `{{with post "2024-01-01-hello"}}<a href="{{.URL}}">{{.Title}}</a>{{end}}|{{with post "nope"}}found{{end}}`,
+		"article.html":                 "{{.Content}}",
+		"articles/2024-01-01-hello.md": "# Hello\n\nText\n",
+		"articles/2024-02-01-other.md": "# Other\n\nText\n",
+	})
+	var log bytes.Buffer
+	buildLog = &logger{out: io.Discard, err: &log}
+	if err := buildSite(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := readOutput(t, "index.html"), "<a href=\"articles/2024-01-01-hello.html\">Hello</a>|\n"; got != want {
+		t.Errorf("index.html = %q, want %q", got, want)
+	}
+	if !strings.Contains(log.String(), `post "nope" not found`) {
+		t.Errorf("unknown slug not reported: %q", log.String())
+	}
+}
+
+func TestBuildExcerptModes(t *testing.T) {
+	const captured = "  <p>First  sentence\n here.   Second <em>one</em>! Third</p></div>  "
+	tests := []struct {
+		name      string
+		captured  string
+		meta      map[string]string
+		mode      string
+		fallback  string
+		wantHTML  string
+		wantPlain string
+	}{
+		{"paragraph", captured, nil, "paragraph", "", "<p>First sentence here. Second <em>one</em>! Third</p>", "First sentence here. Second one! Third"},
+		{"cut off", "<p>Cut <em>off", nil, "", "", "<p>Cut <em>off</em></p>", "Cut off"},
+		{"sentences:1", captured, nil, "sentences:1", "", "First sentence here.", "First sentence here."},
+		{"sentences:2", captured, nil, "sentences:2", "", "First sentence here. Second one!", "First sentence here. Second one!"},
+		{"summary", captured, map[string]string{"summary": "  A <short>\n  summary "}, "", "", "A &lt;short&gt; summary", "A <short> summary"},
+		{"excerpt", captured, map[string]string{"excerpt": "  Some **bold**   text "}, "sentences:1", "", "Some <strong>bold</strong> text", "Some bold text"},
+		{"fallback", " <p> </p> ", nil, "", "title", "Post &amp; more", "Post & more"},
+		{"no fallback", " <p> </p> ", nil, "", "", "<p> </p>", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := config
+			defer func() { config = saved }()
+			config.ExcerptFallback = tt.fallback
+			post := Post{Title: "Post & more"}
+			buildExcerpt(&post, tt.captured, tt.meta, tt.mode)
+			if got := string(post.ExcerptHTML); got != tt.wantHTML {
+				t.Errorf("ExcerptHTML = %q, want %q", got, tt.wantHTML)
+			}
+			if post.Excerpt != tt.wantPlain {
+				t.Errorf("Excerpt = %q, want %q", post.Excerpt, tt.wantPlain)
+			}
+			for _, s := range []string{post.Excerpt, string(post.ExcerptHTML)} {
+				if s != collapseSpace(s) {
+					t.Errorf("%q is not trimmed", s)
+				}
+			}
+			if got := string(post.ExcerptHTML); balanceHTML(got) != got {
+				t.Errorf("%q is not balanced", got)
+			}
+		})
+	}
+}
+
+func TestConcurrentRebuilds(t *testing.T) {
+	defer func(f func() error) { buildFunc = f }(buildFunc)
+	testSite(t, nil)
+	var running, overlaps, calls, seen, last atomic.Int32
+	buildFunc = func() error {
+		if running.Add(1) > 1 {
+			overlaps.Add(1)
+		}
+		calls.Add(1)
+		// every build picks up all changes made before it started
+		seen.Store(last.Load())
+		time.Sleep(5 * time.Millisecond)
+		running.Add(-1)
+		return nil
+	}
+	// like the watcher, trigger each change in the background
+	var wg sync.WaitGroup
+	for i := range 50 {
+		last.Store(int32(i + 1))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rebuild()
+		}()
+	}
+	wg.Wait()
+	if overlaps.Load() > 0 {
+		t.Errorf("%d overlapping builds", overlaps.Load())
+	}
+	if got := calls.Load(); got >= 50 {
+		t.Errorf("%d builds for 50 triggers, queued ones should be folded", got)
+	}
+	if seen.Load() != 50 {
+		t.Errorf("the last build saw change %d, want 50", seen.Load())
+	}
+}
+
+func TestOptionsRerenderPages(t *testing.T) {
+	testSite(t, map[string]string{
+		"index.html":                  "",
+		"article.html":                "<p>{{.Slogan}}</p>\n\n<!-- comment -->\n{{.Content}}",
+		"articles/2024-01-01-post.md": "# Post\n\nText\n",
+	})
+	const page = "public/articles/2024-01-01-post.html"
+	build := func() string {
+		t.Helper()
+		var log bytes.Buffer
+		buildLog = &logger{out: &log, err: &log}
+		if err := buildSite(); err != nil {
+			t.Fatal(err)
+		}
+		return log.String()
+	}
+	build()
+	if got := build(); !strings.Contains(got, "up to date: "+page) {
+		t.Fatalf("unchanged page was rendered again:\n%s", got)
+	}
+	minify = true
+	if got := build(); !strings.Contains(got, "writing: "+page) {
+		t.Errorf("-minify did not re-render the page:\n%s", got)
+	}
+	if got := readOutput(t, "articles/2024-01-01-post.html"); strings.Contains(got, "<!-- comment -->") {
+		t.Errorf("page is not minified:\n%s", got)
+	}
+	defer func(c Config) { compiledConfig = c }(compiledConfig)
+	compiledConfig.Author = "Someone else"
+	if got := build(); strings.Contains(got, "up to date: "+page) {
+		t.Errorf("other compiled-in settings did not re-render the page:\n%s", got)
+	}
+	config.Slogan = "New slogan"
+	build()
+	if got := readOutput(t, "articles/2024-01-01-post.html"); !strings.Contains(got, "New slogan") {
+		t.Errorf("page lacks the new slogan:\n%s", got)
+	}
+	if got := build(); !strings.Contains(got, "up to date: "+page) {
+		t.Errorf("page was rendered again with the same options:\n%s", got)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// manifest records every output of the current build with its content hash,
+// so deploy tooling can upload changed files and prune stale ones, and the
+// hash of the options the article pages were rendered with.
+var manifest = struct {
+	sync.Mutex
+	files   map[string]string
+	options string
+}{files: map[string]string{}}
+
+// resetManifest starts the manifest of a new build, keeping the options of
+// the last one until recordOptions replaces them.
+func resetManifest() {
+	manifest.Lock()
+	defer manifest.Unlock()
+	manifest.files = map[string]string{}
+	manifest.options = ""
+	if data, err := os.ReadFile(filepath.Join(outputDir, ".manifest.json")); err == nil {
+		var previous manifestFile
+		if json.Unmarshal(data, &previous) == nil {
+			manifest.options = previous.Options
+		}
+	}
+}
+
+// compiledConfig is the configuration from data.go, before config.json
+// changes it.
+var compiledConfig = config
+
+// optionsHash hashes the configuration, compiled-in and effective, and the
+// flags that shape every page, so a change to them, e.g. toggling -minify or
+// rebuilding the binary with other settings, re-renders pages that are newer
+// than their post.
+func optionsHash() string {
+	data, _ := json.Marshal(struct {
+		Compiled, Config Config
+		Minify           bool
+	}{compiledConfig, config, minify})
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+// renderedOptions returns the options hash the existing pages were rendered
+// with, as recorded by the last build.
+func renderedOptions() string {
+	manifest.Lock()
+	defer manifest.Unlock()
+	return manifest.options
+}
+
+// recordOptions notes that all pages are rendered with the options hash.
+func recordOptions(hash string) {
+	manifest.Lock()
+	defer manifest.Unlock()
+	manifest.options = hash
+}
+
+// recordOutput adds path, as written to disk, to the manifest.
+func recordOutput(path string, content []byte) {
+	rel, err := filepath.Rel(outputDir, path)
+	if err != nil {
+		rel = path
+	}
+	manifest.Lock()
+	defer manifest.Unlock()
+	manifest.files[filepath.ToSlash(rel)] = fmt.Sprintf("%x", sha256.Sum256(content))
+}
+
+// recorded reports whether path is an output of the current build so far.
+func recorded(path string) bool {
+	rel, err := filepath.Rel(outputDir, path)
+	if err != nil {
+		rel = path
+	}
+	manifest.Lock()
+	defer manifest.Unlock()
+	_, ok := manifest.files[filepath.ToSlash(rel)]
+	return ok
+}
+
+// recordExisting adds outputs that were left untouched by this build.
+func recordExisting(paths ...string) {
+	for _, path := range paths {
+		if data, err := os.ReadFile(path); err == nil {
+			recordOutput(path, data)
+		}
+	}
+}
+
+type manifestFile struct {
+	Options string          `json:"options"`
+	Files   []manifestEntry `json:"files"`
+}
+
+type manifestEntry struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+func writeManifest() error {
+	manifest.Lock()
+	m := manifestFile{Options: manifest.options, Files: make([]manifestEntry, 0, len(manifest.files))}
+	for path, hash := range manifest.files {
+		m.Files = append(m.Files, manifestEntry{path, hash})
+	}
+	manifest.Unlock()
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+	data, _ := json.MarshalIndent(m, "", "  ")
+	return os.WriteFile(filepath.Join(outputDir, ".manifest.json"), append(data, '\n'), 0644)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestManifestListsOutputs(t *testing.T) {
+	testSite(t, map[string]string{
+		"index.html":                  "{{range .Posts}}{{.Title}}{{end}}",
+		"article.html":                "{{.Content}}",
+		"all.html":                    "{{range .Posts}}{{.Content}}{{end}}",
+		"tag.html":                    "{{.Title}}",
+		"style.css":                   "body {}",
+		"static/robots.txt":           "User-agent: *\n",
+		"articles/2024-01-01-post.md": "---\ntags: [go]\n---\n# Post\n\nText\n",
+	})
+	for range 2 { // the second build leaves the feed and sitemap alone
+		if err := buildSite(); err != nil {
+			t.Fatal(err)
+		}
+		var m manifestFile
+		if err := json.Unmarshal([]byte(readOutput(t, ".manifest.json")), &m); err != nil {
+			t.Fatal(err)
+		}
+		if m.Options != optionsHash() {
+			t.Errorf("manifest options %q, want %q", m.Options, optionsHash())
+		}
+		listed := map[string]string{}
+		for _, e := range m.Files {
+			listed[e.Path] = e.SHA256
+		}
+		written := 0
+		filepath.WalkDir(outputDir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || d.Name() == ".manifest.json" {
+				return err
+			}
+			written++
+			rel, _ := filepath.Rel(outputDir, path)
+			data, _ := os.ReadFile(path)
+			if want := fmt.Sprintf("%x", sha256.Sum256(data)); listed[filepath.ToSlash(rel)] != want {
+				t.Errorf("%s: manifest hash %q, want %q", rel, listed[filepath.ToSlash(rel)], want)
+			}
+			return nil
+		})
+		if written != len(m.Files) {
+			t.Errorf("manifest lists %d files, the build wrote %d", len(m.Files), written)
+		}
+		for _, name := range []string{"index.html", "articles/2024-01-01-post.html", "all.html", "tags/go.html", "style.css", "robots.txt", "feed.xml", "rss.xml", "sitemap.xml"} {
+			if listed[name] == "" {
+				t.Errorf("manifest lacks %s", name)
+			}
+		}
+	}
+}
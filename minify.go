@@ -0,0 +1,83 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// elements whose whitespace is significant are left untouched
+	htmlPreservedRe = regexp.MustCompile(`(?is)<pre\b.*?</pre>|<code\b.*?</code>|<textarea\b.*?</textarea>|<script\b.*?</script>|<style\b.*?</style>`)
+	htmlCommentRe   = regexp.MustCompile(`(?s)<!--.*?-->`)
+	htmlSpaceRe     = regexp.MustCompile(`\s+`)
+	// whitespace next to block-level tags doesn't render, unlike the space
+	// between two inline elements
+	htmlBlockTags     = `html|head|body|meta|link|title|base|div|p|ul|ol|li|dl|dt|dd|h[1-6]|header|footer|nav|main|section|article|aside|figure|figcaption|blockquote|table|thead|tbody|tfoot|tr|th|td|caption|form|fieldset|hr|br|!doctype`
+	htmlBlockAfterRe  = regexp.MustCompile(`(?i)(</?(?:` + htmlBlockTags + `)\b[^>]*>) `)
+	htmlBlockBeforeRe = regexp.MustCompile(`(?i) (</?(?:` + htmlBlockTags + `)\b)`)
+)
+
+// minifyHTML drops comments, except conditional ones, collapses whitespace
+// runs to a single space and removes whitespace around block-level tags.
+// <pre>, <code>, <textarea>, <script> and <style> elements are kept as is.
+func minifyHTML(content []byte) []byte {
+	var kept []string
+	s := htmlPreservedRe.ReplaceAllStringFunc(string(content), func(m string) string {
+		kept = append(kept, m)
+		return "\x00" + strconv.Itoa(len(kept)-1) + "\x00"
+	})
+	s = htmlCommentRe.ReplaceAllStringFunc(s, func(m string) string {
+		if strings.HasPrefix(m, "<!--[if") || strings.HasPrefix(m, "<!--<![endif]") {
+			return m
+		}
+		return ""
+	})
+	s = htmlSpaceRe.ReplaceAllString(s, " ")
+	s = htmlBlockAfterRe.ReplaceAllString(s, "$1")
+	s = htmlBlockBeforeRe.ReplaceAllString(s, "$1")
+	s = strings.TrimSpace(s)
+	for i, m := range kept {
+		s = strings.Replace(s, "\x00"+strconv.Itoa(i)+"\x00", m, 1)
+	}
+	return []byte(s)
+}
+
+var (
+	cssCommentRe = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	// strings and url() values are kept as written
+	cssPreservedRe = regexp.MustCompile(`"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'|(?i:url)\([^)]*\)`)
+	// no space is needed around these, unlike the + and - in calc() or the
+	// descendant combinator
+	cssPunctRe = regexp.MustCompile(` ?([{};,>]) ?`)
+	cssColonRe = regexp.MustCompile(`: `)
+)
+
+// minifyCSS strips comments and whitespace that doesn't change the meaning
+// of the stylesheet.
+func minifyCSS(content []byte) []byte {
+	var kept []string
+	s := cssPreservedRe.ReplaceAllStringFunc(string(content), func(m string) string {
+		kept = append(kept, m)
+		return "\x00" + strconv.Itoa(len(kept)-1) + "\x00"
+	})
+	s = cssCommentRe.ReplaceAllString(s, "")
+	s = htmlSpaceRe.ReplaceAllString(s, " ")
+	s = cssPunctRe.ReplaceAllString(s, "$1")
+	s = cssColonRe.ReplaceAllString(s, ":")
+	s = strings.ReplaceAll(s, ";}", "}")
+	s = strings.TrimSpace(s)
+	for i, m := range kept {
+		s = strings.Replace(s, "\x00"+strconv.Itoa(i)+"\x00", m, 1)
+	}
+	return []byte(s)
+}
+
+// stylesheetLinkRe matches the templates' link to style.css.
+var stylesheetLinkRe = regexp.MustCompile(`<link rel="stylesheet" href="(?:[^"]*/)?style\.css"\s*/?>`)
+
+// inlineCSS replaces the link to style.css in a page with a <style> element
+// holding css.
+func inlineCSS(page, css []byte) []byte {
+	return stylesheetLinkRe.ReplaceAllLiteral(page, []byte("<style>"+string(css)+"</style>"))
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"html/template"
+	"path/filepath"
+)
+
+// notFoundRecent is the number of recent posts listed on the 404 page.
+const notFoundRecent = 5
+
+// defaultNotFound is used when there is no 404.html template. Static hosts
+// serve the page for any missing path, so its links are absolute.
+const defaultNotFound = `<!doctype html>
+<html>
+    <head>
+        <meta name="viewport" content="width=device-width, initial-scale=1" />
+        <meta name="robots" content="noindex" />
+        <title>{{.Title}} - Page not found</title>
+        <link rel="stylesheet" href="{{.BaseURL}}/style.css" />
+        {{.Analytics}}
+    </head>
+    <body>
+        <nav>
+            <a href="{{.BaseURL}}/index.html">{{.Title}}</a>
+            <p style="font-family: monospace; text-align: right">{{.Slogan}}</p>
+        </nav>
+        <section>
+            <h2>Page not found</h2>
+            <p>There is nothing here. Maybe one of the latest posts?</p>
+            <ul>
+                {{range .Posts}}
+                <li><a href="{{$.BaseURL}}/{{.URL}}">{{.Title}}</a></li>
+                {{end}}
+            </ul>
+        </section>
+        <footer>
+            <a href="{{.BaseURL}}/index.html">Back to home</a>
+        </footer>
+    </body>
+</html>
+`
+
+// generateNotFound writes 404.html from the 404.html template, or the
+// built-in page, listing the most recent posts.
+func generateNotFound(posts []Post) error {
+	tmpl, err := template.New("404").Funcs(funcMap).Parse(defaultNotFound)
+	if fileExists("404.html") {
+		tmpl, err = loadTemplate("404.html")
+	}
+	if err != nil {
+		return err
+	}
+	recent := posts
+	if len(recent) > notFoundRecent {
+		recent = recent[:notFoundRecent]
+	}
+	return renderPage(tmpl, filepath.Join(outputDir, "404.html"), map[string]any{"Title": config.Title, "Slogan": config.Slogan, "BaseURL": config.BaseURL, "Posts": recent, "Analytics": analyticsSnippet()})
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"html/template"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultPermalink is where posts live unless config.PermalinkPattern says
+// otherwise. Relative links in posts are written against it.
+const defaultPermalink = "/articles/:slug.html"
+
+// permalink returns the URL path of a post below the site root, without the
+// leading slash. Patterns ending in "/" are directories holding an
+// index.html.
+func permalink(slug string, date time.Time) string {
+	pattern := config.PermalinkPattern
+	if pattern == "" {
+		pattern = defaultPermalink
+	}
+	return strings.TrimPrefix(strings.NewReplacer(
+		":year", date.Format("2006"),
+		":month", date.Format("01"),
+		":day", date.Format("02"),
+		":slug", slug,
+	).Replace(pattern), "/")
+}
+
+// postFile is the output file of a post's page.
+func postFile(p Post) string {
+	file := filepath.Join(outputDir, filepath.FromSlash(p.URL))
+	if strings.HasSuffix(p.URL, "/") {
+		file = filepath.Join(file, "index.html")
+	}
+	return file
+}
+
+// ampURL is the URL path of a post's AMP page, next to the regular one.
+func ampURL(p Post) string {
+	if strings.HasSuffix(p.URL, "/") {
+		return p.URL + "index.amp.html"
+	}
+	return strings.TrimSuffix(p.URL, ".html") + ".amp.html"
+}
+
+// pageRoot is the relative path from the directory of url back to the site
+// root, e.g. "../" for "articles/x.html".
+func pageRoot(url string) string {
+	return strings.Repeat("../", strings.Count(url, "/"))
+}
+
+// relocateLinks rebases the relative links of posts, which are written
+// against the default location. The content of posts living elsewhere
+// follows them to their permalink, the excerpt moves to the site root, where
+// the index pages render it, and links to other posts follow those to their
+// permalink.
+func relocateLinks(posts []Post) {
+	moved := map[string]string{}
+	for _, p := range posts {
+		moved["articles/"+p.Slug+".html"] = p.URL
+	}
+	relocate := func(content template.HTML, dir string) template.HTML {
+		return template.HTML(rewriteLinks(string(content), func(ref string) string {
+			if !isRelativeURL(ref) {
+				return ref
+			}
+			target, rest := ref, ""
+			if i := strings.IndexAny(ref, "?#"); i >= 0 {
+				target, rest = ref[:i], ref[i:]
+			}
+			if target == "" {
+				return ref
+			}
+			target = path.Join("articles", target)
+			if url, ok := moved[target]; ok {
+				target = url
+			}
+			rel, err := filepath.Rel(dir, target)
+			if err != nil {
+				return ref
+			}
+			rel = filepath.ToSlash(rel)
+			if strings.HasSuffix(target, "/") {
+				rel += "/"
+			}
+			return rel + rest
+		}))
+	}
+	custom := config.PermalinkPattern != "" && config.PermalinkPattern != defaultPermalink
+	for i, p := range posts {
+		if custom {
+			posts[i].Content = relocate(p.Content, path.Dir(p.URL))
+		}
+		posts[i].ExcerptHTML = relocate(p.ExcerptHTML, ".")
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPermalinkLinks(t *testing.T) {
+	tests := []struct {
+		pattern string
+		page    string
+		article []string // links on the post's page
+		index   []string // links in its excerpt on the index
+	}{
+		{"", "articles/2024-01-01-a.html",
+			[]string{`href="2024-02-01-b.html"`, `src="../images/p.png"`, `href="2024-02-01-b.html#x"`},
+			[]string{`href="articles/2024-02-01-b.html"`, `src="images/p.png"`}},
+		{"/:year/:month/:slug/", "2024/01/2024-01-01-a/index.html",
+			[]string{`href="../../02/2024-02-01-b/"`, `src="../../../images/p.png"`, `href="../../02/2024-02-01-b/#x"`},
+			[]string{`href="2024/02/2024-02-01-b/"`, `src="images/p.png"`}},
+		{"/posts/:slug.html", "posts/2024-01-01-a.html",
+			[]string{`href="2024-02-01-b.html"`, `src="../images/p.png"`, `href="2024-02-01-b.html#x"`},
+			[]string{`href="posts/2024-02-01-b.html"`, `src="images/p.png"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			testSite(t, map[string]string{
+				"index.html":               "{{range .Posts}}{{.ExcerptHTML}}\n{{end}}",
+				"article.html":             "{{.Content}}",
+				"public/images/p.png":      "png",
+				"articles/2024-01-01-a.md": "# A\n\nSee [b](2024-02-01-b.html) and ![pic](../images/p.png)\n\nMore on [b](2024-02-01-b.html#x)\n",
+				"articles/2024-02-01-b.md": "# B\n\n## X\n\nText\n",
+			})
+			config.PermalinkPattern = tt.pattern
+			if err := buildSite(); err != nil {
+				t.Fatal(err)
+			}
+			page := readOutput(t, tt.page)
+			for _, want := range tt.article {
+				if !strings.Contains(page, want) {
+					t.Errorf("%s lacks %s:\n%s", tt.page, want, page)
+				}
+			}
+			index := readOutput(t, "index.html")
+			for _, want := range tt.index {
+				if !strings.Contains(index, want) {
+					t.Errorf("index.html lacks %s:\n%s", want, index)
+				}
+			}
+		})
+	}
+}
+
+func TestPermalinkCover(t *testing.T) {
+	tests := []struct {
+		pattern string
+		cover   string
+		want    string
+	}{
+		{"", "../images/cover.png", "https://example.com/images/cover.png"},
+		{"/:year/:month/:slug/", "cover.png", "https://example.com/2024/01/2024-01-01-post/cover.png"},
+		{"/:year/:month/:slug/", "../../../images/cover.png", "https://example.com/images/cover.png"},
+		{"/posts/:slug.html", "/images/cover.png", "https://example.com/images/cover.png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.cover, func(t *testing.T) {
+			testSite(t, map[string]string{
+				"index.html":                  "{{range .Posts}}{{.Cover}}{{end}}",
+				"article.html":                "{{.Cover}}",
+				"articles/2024-01-01-post.md": "---\ncover: " + tt.cover + "\n---\n# Post\n\nText\n",
+			})
+			config.PermalinkPattern = tt.pattern
+			if err := buildSite(); err != nil {
+				t.Fatal(err)
+			}
+			if got := readOutput(t, "index.html"); got != tt.want+"\n" {
+				t.Errorf("cover = %q, want %q", got, tt.want)
+			}
+			if got := readOutput(t, "feed.xml"); !strings.Contains(got, `href="`+tt.want+`"`) {
+				t.Errorf("feed.xml lacks the cover %s:\n%s", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// contentTypes pins the types of the files the build produces instead of
+// relying on the system's MIME database.
+var contentTypes = map[string]string{
+	".html": "text/html; charset=utf-8",
+	".css":  "text/css; charset=utf-8",
+	".xml":  "application/xml; charset=utf-8",
+	".png":  "image/png",
+}
+
+// staticHandler serves root the way typical static hosts do: directory
+// requests get their index.html, directories requested without a trailing
+// slash are redirected, missing paths get 404.html and there are no directory
+// listings. A non-empty inject is inserted before </body> of every HTML
+// response, the files on disk stay untouched.
+func staticHandler(root, inject string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		urlPath := path.Clean("/" + r.URL.Path)
+		name := filepath.Join(root, filepath.FromSlash(urlPath))
+		info, err := os.Stat(name)
+		if err == nil && info.IsDir() {
+			if !strings.HasSuffix(r.URL.Path, "/") {
+				http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+				return
+			}
+			name = filepath.Join(name, "index.html")
+			info, err = os.Stat(name)
+		}
+		if err != nil || info.IsDir() {
+			// like static hosts, answer with the site's 404.html if there is one
+			page, err := os.ReadFile(filepath.Join(root, "404.html"))
+			if err != nil {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write(page)
+			return
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+		if ct, ok := contentTypes[strings.ToLower(filepath.Ext(name))]; ok {
+			w.Header().Set("Content-Type", ct)
+		}
+		if inject != "" && strings.EqualFold(filepath.Ext(name), ".html") {
+			page, err := os.ReadFile(name)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if i := bytes.LastIndex(page, []byte("</body>")); i >= 0 {
+				page = append(page[:i:i], append([]byte(inject), page[i:]...)...)
+			} else {
+				page = append(page, inject...)
+			}
+			http.ServeContent(w, r, name, info.ModTime(), bytes.NewReader(page))
+			return
+		}
+		http.ServeContent(w, r, name, info.ModTime(), f)
+	})
+}
+
+const liveReloadPath = "/_livereload"
+
+// liveReloadScript reloads the page when the server reports a finished build.
+// EventSource reconnects on its own when the server restarts.
+const liveReloadScript = `<script>new EventSource("` + liveReloadPath + `").onmessage = () => location.reload();</script>
+`
+
+// liveReload streams a server-sent event to every connected page after each
+// build.
+type liveReload struct {
+	mu      sync.Mutex
+	clients map[chan struct{}]bool
+}
+
+func (lr *liveReload) notify() {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	for c := range lr.clients {
+		select {
+		case c <- struct{}{}:
+		default: // a reload is already pending for this client
+		}
+	}
+}
+
+func (lr *liveReload) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	c := make(chan struct{}, 1)
+	lr.mu.Lock()
+	lr.clients[c] = true
+	lr.mu.Unlock()
+	defer func() {
+		lr.mu.Lock()
+		delete(lr.clients, c)
+		lr.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+	for {
+		select {
+		case <-c:
+			fmt.Fprint(w, "data: reload\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func runServeCommand(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	port := fs.Int("port", 8080, "Port to listen on")
+	watch := fs.Bool("watch", false, "Rebuild site on file changes while serving (requires -tags watch)")
+	fs.Parse(args)
+
+	if err := buildSite(); err != nil {
+		// keep serving what was built, the next rebuild may fix it
+		buildLog.Warnf("", "build failed with %v", err)
+	}
+	mux := http.NewServeMux()
+	if *watch {
+		// only pages served here get the reload script, public/ stays clean
+		lr := &liveReload{clients: map[chan struct{}]bool{}}
+		afterBuild = lr.notify
+		mux.Handle(liveReloadPath, lr)
+		mux.Handle("/", staticHandler(outputDir, liveReloadScript))
+		fmt.Println("Watching for changes...")
+		go watchFiles()
+	} else {
+		mux.Handle("/", staticHandler(outputDir, ""))
+	}
+	addr := fmt.Sprintf("localhost:%d", *port)
+	fmt.Printf("Serving %s/ on http://%s/\n", outputDir, addr)
+	log.Fatal(http.ListenAndServe(addr, mux))
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStaticHandlerDirectories(t *testing.T) {
+	testSite(t, map[string]string{
+		"public/index.html":                  "home",
+		"public/2024/01/post/index.html":     "post",
+		"public/2024/01/post/index.amp.html": "amp",
+		"public/404.html":                    "not found",
+	})
+	tests := []struct {
+		path     string
+		status   int
+		body     string
+		location string
+	}{
+		{"/", http.StatusOK, "home", ""},
+		{"/2024/01/post/", http.StatusOK, "post", ""},
+		{"/2024/01/post", http.StatusMovedPermanently, "", "/2024/01/post/"},
+		{"/2024/", http.StatusNotFound, "not found", ""},
+		{"/missing.html", http.StatusNotFound, "not found", ""},
+	}
+	handler := staticHandler(outputDir, "")
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+		if rec.Code != tt.status {
+			t.Errorf("GET %s: status %d, want %d", tt.path, rec.Code, tt.status)
+		}
+		if tt.body != "" && rec.Body.String() != tt.body {
+			t.Errorf("GET %s: body %q, want %q", tt.path, rec.Body.String(), tt.body)
+		}
+		if got := rec.Header().Get("Location"); got != tt.location {
+			t.Errorf("GET %s: redirected to %q, want %q", tt.path, got, tt.location)
+		}
+		if tt.status == http.StatusOK && rec.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+			t.Errorf("GET %s: content type %q", tt.path, rec.Header().Get("Content-Type"))
+		}
+	}
+}
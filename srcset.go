@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// srcsetVariantRe matches the suffix `image -srcset` gives its variants,
+// name@400w.png for name.png. The "@" keeps ordinary images like chart-2.png
+// next to chart.png from being taken for variants.
+var srcsetVariantRe = regexp.MustCompile(`^@\d+w\.png$`)
+
+// imageSrcset returns a srcset attribute listing the variants of an already
+// escaped image src that exist under public/, or "" if there are none. Like
+// every relative link in a post, src is relative to articles/.
+func imageSrcset(src string) string {
+	src = html.UnescapeString(src)
+	file, ok := localImage(src, "articles")
+	if !ok {
+		return ""
+	}
+	entries, err := os.ReadDir(filepath.Dir(file))
+	if err != nil {
+		return ""
+	}
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	stem := strings.TrimSuffix(src, path.Ext(src))
+	type variant struct {
+		url   string
+		width int
+	}
+	var variants []variant
+	for _, e := range entries {
+		suffix, ok := strings.CutPrefix(e.Name(), name)
+		if !ok || !srcsetVariantRe.MatchString(suffix) {
+			continue
+		}
+		if width, _, err := imageSize(stem+suffix, "articles"); err == nil {
+			variants = append(variants, variant{stem + suffix, width})
+		}
+	}
+	if len(variants) == 0 {
+		return ""
+	}
+	sort.Slice(variants, func(i, j int) bool { return variants[i].width < variants[j].width })
+	candidates := make([]string, len(variants))
+	for i, v := range variants {
+		candidates[i] = fmt.Sprintf("%s %dw", v.url, v.width)
+	}
+	return ` srcset="` + html.EscapeString(strings.Join(candidates, ", ")) + `"`
+}
+
+// srcsetURLs returns the URLs of the candidates in a srcset value.
+func srcsetURLs(value string) []string {
+	var urls []string
+	for _, candidate := range strings.Split(value, ",") {
+		if fields := strings.Fields(candidate); len(fields) > 0 {
+			urls = append(urls, fields[0])
+		}
+	}
+	return urls
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func TestImageSrcset(t *testing.T) {
+	pngOf := func(w, h int) string {
+		var buf bytes.Buffer
+		png.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h)))
+		return buf.String()
+	}
+	testSite(t, map[string]string{
+		"public/images/chart.png":         pngOf(800, 600),
+		"public/images/chart-2.png":       pngOf(640, 480), // another image, not a variant
+		"public/images/chart@400w.png":    pngOf(400, 300),
+		"public/images/chart@200w.png":    pngOf(200, 150),
+		"public/images/chart@x.png":       pngOf(100, 75),
+		"public/images/portrait.png":      pngOf(300, 400),
+		"public/images/portrait@200w.png": pngOf(150, 200),
+		"public/images/table.png":         pngOf(100, 100),
+		"public/images/table-1.png":       pngOf(100, 100),
+	})
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"../images/chart.png", ` srcset="../images/chart@200w.png 200w, ../images/chart@400w.png 400w"`},
+		// the size is the long edge, the descriptor the measured width
+		{"../images/portrait.png", ` srcset="../images/portrait@200w.png 150w"`},
+		{"../images/table.png", ""},
+		{"https://example.com/images/chart.png", ""},
+	}
+	for _, tt := range tests {
+		if got := imageSrcset(tt.src); got != tt.want {
+			t.Errorf("imageSrcset(%q) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
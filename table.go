@@ -0,0 +1,95 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+var tableSeparatorRe = regexp.MustCompile(`^\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?$`)
+
+// splitTableRow splits a pipe delimited row into trimmed cells. Outer pipes
+// are optional and \| is a literal pipe.
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if !strings.HasSuffix(line, `\|`) {
+		line = strings.TrimSuffix(line, "|")
+	}
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// tableAlignments parses a separator row like |:---|:--:|---:| into
+// text-align values, or returns nil if line isn't one.
+func tableAlignments(line string) []string {
+	if !tableSeparatorRe.MatchString(strings.TrimSpace(line)) {
+		return nil
+	}
+	var aligns []string
+	for _, cell := range splitTableRow(line) {
+		left, right := strings.HasPrefix(cell, ":"), strings.HasSuffix(cell, ":")
+		switch {
+		case left && right:
+			aligns = append(aligns, "center")
+		case right:
+			aligns = append(aligns, "right")
+		case left:
+			aligns = append(aligns, "left")
+		default:
+			aligns = append(aligns, "")
+		}
+	}
+	return aligns
+}
+
+// isTableStart reports whether lines[i] is a header row followed by a
+// separator row with the same number of columns.
+func isTableStart(lines []string, i int) bool {
+	if !strings.Contains(lines[i], "|") || i+1 >= len(lines) {
+		return false
+	}
+	aligns := tableAlignments(lines[i+1])
+	return aligns != nil && len(aligns) == len(splitTableRow(lines[i]))
+}
+
+// renderTable renders the header, the separator and the body rows. Rows with
+// missing cells are padded, extra cells are dropped.
+func renderTable(header string, aligns []string, rows []string, inline func(string) string) string {
+	var out strings.Builder
+	row := func(tag string, cells []string) {
+		out.WriteString("<tr>")
+		for i, align := range aligns {
+			cell := ""
+			if i < len(cells) {
+				cell = inline(cells[i])
+			}
+			if align != "" {
+				out.WriteString("<" + tag + " style=\"text-align:" + align + "\">" + cell + "</" + tag + ">")
+			} else {
+				out.WriteString("<" + tag + ">" + cell + "</" + tag + ">")
+			}
+		}
+		out.WriteString("</tr>\n")
+	}
+	out.WriteString("<table>\n<thead>\n")
+	row("th", splitTableRow(header))
+	out.WriteString("</thead>\n<tbody>\n")
+	for _, r := range rows {
+		row("td", splitTableRow(r))
+	}
+	out.WriteString("</tbody>\n</table>\n")
+	return out.String()
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Tag is a front matter tag with the posts carrying it, newest first.
+type Tag struct {
+	Name  string
+	Slug  string // tagSlug(Name), the page is tags/<Slug>.html
+	Posts []Post
+}
+
+// indexTagSlug replaces the slug "index", whose page would be the tag overview.
+const indexTagSlug = "index-tag"
+
+// tagSlug is the page name of a tag, the sanitized name unless that is the
+// reserved "index".
+func tagSlug(name string) string {
+	if slug := sanitizeAnchor(name); slug != "index" {
+		return slug
+	}
+	return indexTagSlug
+}
+
+// collectTags groups posts by tag slug. Tags that only differ in what
+// sanitizeAnchor removes, like "Go" and "go", are merged under the first name
+// seen with a warning. The result is sorted by name.
+func collectTags(posts []Post) []*Tag {
+	bySlug := map[string]*Tag{}
+	var tags []*Tag
+	for _, p := range posts {
+		seen := map[string]bool{}
+		for _, name := range p.Tags {
+			slug := tagSlug(name)
+			t, ok := bySlug[slug]
+			if !ok {
+				t = &Tag{Name: name, Slug: slug}
+				bySlug[slug] = t
+				tags = append(tags, t)
+				if slug == indexTagSlug && sanitizeAnchor(name) == "index" {
+					buildLog.Warnf(p.Source, "tag %q would replace the tag overview, it becomes tags/%s.html", name, slug)
+				}
+			} else if t.Name != name {
+				buildLog.Warnf(p.Source, "tag %q merged into %q, both become tags/%s.html", name, t.Name, slug)
+			}
+			if !seen[slug] {
+				seen[slug] = true
+				t.Posts = append(t.Posts, p)
+			}
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool { return strings.ToLower(tags[i].Name) < strings.ToLower(tags[j].Name) })
+	return tags
+}
+
+// generateTags writes tags/<tag>.html for every tag and tags/index.html
+// listing all of them, both from the tag.html template.
+func generateTags(posts []Post) error {
+	tags := collectTags(posts)
+	if !fileExists("tag.html") || len(tags) == 0 {
+		return nil
+	}
+	tmpl, err := loadTemplate("tag.html")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(outputDir, "tags"), 0755); err != nil {
+		return err
+	}
+	var errs []error
+	for _, t := range tags {
+		errs = append(errs, renderPage(tmpl, filepath.Join(outputDir, "tags", t.Slug+".html"), map[string]any{"Title": config.Title, "Tag": t, "Slogan": config.Slogan, "Analytics": analyticsSnippet()}))
+	}
+	errs = append(errs, renderPage(tmpl, filepath.Join(outputDir, "tags", "index.html"), map[string]any{"Title": config.Title, "Tags": tags, "Slogan": config.Slogan, "Analytics": analyticsSnippet()}))
+	return errors.Join(errs...)
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestIndexTag(t *testing.T) {
+	testSite(t, map[string]string{
+		"index.html":                  "",
+		"article.html":                "{{.Content}}",
+		"tag.html":                    "{{with .Tag}}{{.Name}}:{{range .Posts}} {{.Title}}{{end}}{{else}}overview:{{range .Tags}} {{.Slug}}{{end}}{{end}}",
+		"articles/2024-01-01-post.md": "---\ntags: [Index, go]\n---\n# Post\n\nText\n",
+	})
+	var log bytes.Buffer
+	buildLog = &logger{out: &log, err: &log}
+	if err := buildSite(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := readOutput(t, "tags/index.html"), "overview: go index-tag\n"; got != want {
+		t.Errorf("tags/index.html = %q, want %q", got, want)
+	}
+	if got, want := readOutput(t, "tags/index-tag.html"), "Index: Post\n"; got != want {
+		t.Errorf("tags/index-tag.html = %q, want %q", got, want)
+	}
+	if !strings.Contains(log.String(), `tag "Index" would replace the tag overview, it becomes tags/index-tag.html`) {
+		t.Errorf("no warning:\n%s", log.String())
+	}
+}
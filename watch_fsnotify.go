@@ -3,37 +3,78 @@
 package main
 
 import (
-	"fmt"
+	"io/fs"
 	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 )
 
+// watchDebounce is how long the watcher waits for more events before it
+// rebuilds, so one editor save (write, chmod, rename, ...) builds once.
+const watchDebounce = 100 * time.Millisecond
+
 func watchFiles() {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer watcher.Close()
-	watchPaths := []string{"articles", "style.css", "main.go", "index.html", "article.html"}
-	for _, path := range watchPaths {
-		if err := watcher.Add(path); err != nil {
-			log.Println("watch error:", err)
-		}
-	}
+	addWatches(watcher)
+	changed := map[string]bool{}
+	timer := time.NewTimer(watchDebounce)
+	timer.Stop()
 	for {
 		select {
 		case event, ok := <-watcher.Events:
 			if !ok {
 				return
 			}
-			fmt.Println("Changed:", event.Name)
-			buildSite()
+			changed[event.Name] = true
+			timer.Reset(watchDebounce)
+		case <-timer.C:
+			var names []string
+			for name := range changed {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			clear(changed)
+			buildLog.Printf("", "Changed: %s", strings.Join(names, ", "))
+			// new subdirectories or recreated files need (new) watches
+			addWatches(watcher)
+			// rebuild in the background so events keep being collected; a
+			// batch arriving during the build is queued behind it
+			go rebuild()
 		case err, ok := <-watcher.Errors:
 			if !ok {
 				return
 			}
-			log.Println("watch error:", err)
+			buildLog.Warnf("watch", "%v", err)
+		}
+	}
+}
+
+// addWatches watches the templates, the posts directory and the static
+// directory with all its subdirectories. Posts are only read from the top
+// level of their directory, so its subdirectories aren't watched. Missing
+// templates are skipped, paths that are already watched are left as they are.
+func addWatches(watcher *fsnotify.Watcher) {
+	watchPaths := []string{"style.css", "main.go", "index.html", "article.html", "listing.html", "all.html", "amp.html", "tag.html", "404.html", inputDir}
+	filepath.WalkDir(staticDir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			watchPaths = append(watchPaths, path)
+		}
+		return nil
+	})
+	for _, path := range watchPaths {
+		if !fileExists(path) {
+			continue
+		}
+		if err := watcher.Add(path); err != nil {
+			buildLog.Warnf("watch", "%v", err)
 		}
 	}
 }
@@ -0,0 +1,33 @@
+//go:build watch
+
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestAddWatches(t *testing.T) {
+	testSite(t, map[string]string{
+		"article.html":                "",
+		"articles/2024-01-01-post.md": "",
+		"articles/drafts/later.md":    "",
+		"static/fonts/a.woff2":        "",
+	})
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+	addWatches(watcher)
+	got := watcher.WatchList()
+	slices.Sort(got)
+	// loadPosts doesn't read subdirectories of the posts directory
+	want := []string{"article.html", "articles", "static", filepath.Join("static", "fonts")}
+	if !slices.Equal(got, want) {
+		t.Errorf("watching %v, want %v", got, want)
+	}
+}